@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// networkBaseline describes the network cost of a lane: read round trips to the
+// endpoint transactions are submitted to and the one receipts are observed on,
+// plus the polling granularity when receipts are polled.
+type networkBaseline struct {
+	Lane            string
+	SubmitRTT       time.Duration
+	ObserveRTT      time.Duration
+	PollingInterval time.Duration
+}
+
+// attribution splits a lane's median inclusion latency into the part explained
+// by the network and the remainder spent in the builder/sequencer.
+type attribution struct {
+	Region       string
+	Lane         string
+	Count        int
+	InclusionP50 time.Duration
+	SubmitRTT    time.Duration
+	ObserveRTT   time.Duration
+	Network      time.Duration
+	Polling      time.Duration
+	Sequencer    time.Duration
+}
+
+// measureRoundTrip returns the median eth_blockNumber round trip to the
+// endpoint, which stands in for its network RTT.
+func measureRoundTrip(client *ethclient.Client, samples int) time.Duration {
+	var rtts []time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := client.BlockNumber(context.Background()); err != nil {
+			log.Printf("Failed to measure round trip: %v", err)
+			continue
+		}
+		rtts = append(rtts, time.Since(start))
+	}
+
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return percentile(rtts, 50)
+}
+
+// attribute estimates where each lane's time goes. The transaction travels half
+// a submit round trip to the endpoint and the receipt half an observe round
+// trip back, and a polled receipt is on average found half an interval late.
+// Whatever is left of the median is attributed to the builder/sequencer.
+func attribute(region string, timings []stats, baselines []networkBaseline) []attribution {
+	summaries := make(map[string]latencySummary)
+	for _, s := range summarizeByLane(timings) {
+		summaries[s.Group] = s
+	}
+
+	var attributions []attribution
+	for _, b := range baselines {
+		s, ok := summaries[b.Lane]
+		if !ok {
+			continue
+		}
+
+		network := (b.SubmitRTT + b.ObserveRTT) / 2
+		polling := b.PollingInterval / 2
+		sequencer := s.P50 - network - polling
+		if sequencer < 0 {
+			sequencer = 0
+		}
+
+		attributions = append(attributions, attribution{
+			Region:       region,
+			Lane:         b.Lane,
+			Count:        s.Count,
+			InclusionP50: s.P50,
+			SubmitRTT:    b.SubmitRTT,
+			ObserveRTT:   b.ObserveRTT,
+			Network:      network,
+			Polling:      polling,
+			Sequencer:    sequencer,
+		})
+	}
+	return attributions
+}
+
+func logAttributions(attributions []attribution) {
+	for _, a := range attributions {
+		log.Printf("region=%s lane=%s p50=%dms network=%dms polling=%dms sequencer=%dms", a.Region, a.Lane, a.InclusionP50.Milliseconds(), a.Network.Milliseconds(), a.Polling.Milliseconds(), a.Sequencer.Milliseconds())
+	}
+}
+
+func writeAttributions(filename string, attributions []attribution) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"region", "lane", "count", "inclusion_p50_ms", "submit_rtt_ms", "observe_rtt_ms", "network_ms", "polling_ms", "sequencer_ms"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, a := range attributions {
+		row := []string{
+			a.Region,
+			a.Lane,
+			strconv.Itoa(a.Count),
+			strconv.FormatInt(a.InclusionP50.Milliseconds(), 10),
+			strconv.FormatInt(a.SubmitRTT.Milliseconds(), 10),
+			strconv.FormatInt(a.ObserveRTT.Milliseconds(), 10),
+			strconv.FormatInt(a.Network.Milliseconds(), 10),
+			strconv.FormatInt(a.Polling.Milliseconds(), 10),
+			strconv.FormatInt(a.Sequencer.Milliseconds(), 10),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// endpointAvailability tracks whether an endpoint answered over the run. A
+// request fails when it can't be delivered or the endpoint answers with a
+// server error; RPC level errors still count as the endpoint being up.
+type endpointAvailability struct {
+	mu          sync.Mutex
+	name        string
+	requests    int
+	failures    int
+	streak      int
+	maxStreak   int
+	downSince   time.Time
+	unreachable time.Duration
+	reconnects  int
+}
+
+type availabilitySnapshot struct {
+	Endpoint    string
+	Requests    int
+	Failures    int
+	Streak      int
+	MaxStreak   int
+	Unreachable time.Duration
+	Reconnects  int
+}
+
+func (a *endpointAvailability) recordSuccess() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+	a.streak = 0
+	if !a.downSince.IsZero() {
+		a.unreachable += time.Since(a.downSince)
+		a.downSince = time.Time{}
+	}
+}
+
+func (a *endpointAvailability) recordFailure() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+	a.failures++
+	a.streak++
+	if a.streak > a.maxStreak {
+		a.maxStreak = a.streak
+	}
+	if a.downSince.IsZero() {
+		a.downSince = time.Now()
+	}
+}
+
+func (a *endpointAvailability) recordReconnect() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.reconnects++
+}
+
+func (a *endpointAvailability) snapshot() availabilitySnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	unreachable := a.unreachable
+	if !a.downSince.IsZero() {
+		unreachable += time.Since(a.downSince)
+	}
+
+	return availabilitySnapshot{
+		Endpoint:    a.name,
+		Requests:    a.requests,
+		Failures:    a.failures,
+		Streak:      a.streak,
+		MaxStreak:   a.maxStreak,
+		Unreachable: unreachable,
+		Reconnects:  a.reconnects,
+	}
+}
+
+// availabilityTracker holds the availability of every endpoint in the run.
+type availabilityTracker struct {
+	mu        sync.Mutex
+	endpoints []*endpointAvailability
+}
+
+func (t *availabilityTracker) endpoint(name string) *endpointAvailability {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, e := range t.endpoints {
+		if e.name == name {
+			return e
+		}
+	}
+	e := &endpointAvailability{name: name}
+	t.endpoints = append(t.endpoints, e)
+	return e
+}
+
+func (t *availabilityTracker) snapshots() []availabilitySnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var snapshots []availabilitySnapshot
+	for _, e := range t.endpoints {
+		snapshots = append(snapshots, e.snapshot())
+	}
+	return snapshots
+}
+
+// availabilityTransport records the outcome of every HTTP request to an endpoint.
+type availabilityTransport struct {
+	base         http.RoundTripper
+	availability *endpointAvailability
+}
+
+func (t *availabilityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if req.Context().Err() != nil {
+		// Cancelled by us, e.g. on TX_DEADLINE_MS, not by the endpoint
+		return resp, err
+	}
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		t.availability.recordFailure()
+	} else {
+		t.availability.recordSuccess()
+	}
+	return resp, err
+}
+
+// dialEndpoint connects to an HTTP endpoint, tracking its availability. With a
+// source, connections are made from that local IP or interface.
+func dialEndpoint(url string, source string, availability *endpointAvailability) (*ethclient.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if source != "" {
+		ip, err := sourceAddr(source)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: ip},
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	httpClient := &http.Client{
+		Transport: &availabilityTransport{
+			base:         transport,
+			availability: availability,
+		},
+	}
+
+	client, err := rpc.DialOptions(context.Background(), url, rpc.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// recordAvailability stamps the row with the state of the endpoint it was
+// submitted to, so latency can be read alongside availability over time.
+func (s *stats) recordAvailability(a availabilitySnapshot) {
+	s.EndpointFailureStreak = a.Streak
+	s.EndpointUnreachable = a.Unreachable
+}
+
+func logAvailability(snapshots []availabilitySnapshot) {
+	for _, s := range snapshots {
+		log.Printf("endpoint=%s requests=%d failures=%d max_streak=%d unreachable=%dms reconnects=%d", s.Endpoint, s.Requests, s.Failures, s.MaxStreak, s.Unreachable.Milliseconds(), s.Reconnects)
+	}
+}
+
+func writeAvailability(filename string, snapshots []availabilitySnapshot) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"endpoint", "requests", "failures", "availability_pct", "max_failure_streak", "unreachable_ms", "reconnects"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, s := range snapshots {
+		availabilityPct := 100.0
+		if s.Requests > 0 {
+			availabilityPct = 100 * float64(s.Requests-s.Failures) / float64(s.Requests)
+		}
+
+		row := []string{
+			s.Endpoint,
+			strconv.Itoa(s.Requests),
+			strconv.Itoa(s.Failures),
+			strconv.FormatFloat(availabilityPct, 'f', 2, 64),
+			strconv.Itoa(s.MaxStreak),
+			strconv.FormatInt(s.Unreachable.Milliseconds(), 10),
+			strconv.Itoa(s.Reconnects),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
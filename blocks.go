@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// blockWaitTimeout is how long after its timestamp a block may stay
+// unavailable before rows are written without their block columns.
+const blockWaitTimeout = 10 * time.Second
+
+// blockQueue holds included rows until their block is sealed, so the block
+// columns can be filled in without waiting between sends. Rows are passed on
+// in the order they were queued, about a block time after they complete, and
+// only the rows of the last block or two are held at once.
+type blockQueue struct {
+	client            *ethclient.Client
+	clock             *blockClock
+	pollingIntervalMs int
+	write             func(stats)
+	rows              []stats
+	last              *types.Block
+}
+
+func newBlockQueue(client *ethclient.Client, clock *blockClock, pollingIntervalMs int, write func(stats)) *blockQueue {
+	return &blockQueue{client: client, clock: clock, pollingIntervalMs: pollingIntervalMs, write: write}
+}
+
+// push queues a row and passes on the queued rows whose blocks are sealed.
+// Sealed blocks are fetched once each; a block the endpoint doesn't have yet
+// is tried again on the next push.
+func (q *blockQueue) push(row stats) {
+	q.rows = append(q.rows, row)
+	for len(q.rows) > 0 {
+		row := q.rows[0]
+		if row.tx != nil {
+			sealedAt := q.clock.blockTimestamp(row.IncludedInBlock)
+			if time.Now().Before(sealedAt) {
+				return
+			}
+
+			block, err := q.block(row.IncludedInBlock)
+			if err != nil && time.Since(sealedAt) < blockWaitTimeout {
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to get block %d for fees: %v", row.IncludedInBlock, err)
+			} else {
+				row.recordBlock(block, row.tx)
+			}
+		}
+		q.write(row)
+		q.rows = q.rows[1:]
+	}
+}
+
+// flush waits for the blocks of the remaining rows and passes them all on. It
+// is called at the end of a phase.
+func (q *blockQueue) flush() {
+	for _, row := range q.rows {
+		if row.tx != nil {
+			block, err := q.waitForBlock(row.IncludedInBlock)
+			if err != nil {
+				log.Printf("Failed to get block %d for fees: %v", row.IncludedInBlock, err)
+			} else {
+				row.recordBlock(block, row.tx)
+			}
+		}
+		q.write(row)
+	}
+	q.rows = nil
+}
+
+// block returns the given block, reusing the last one fetched since
+// consecutive rows often share it.
+func (q *blockQueue) block(blockNumber uint64) (*types.Block, error) {
+	if q.last != nil && q.last.NumberU64() == blockNumber {
+		return q.last, nil
+	}
+
+	block, err := q.client.BlockByNumber(context.Background(), new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get block: %v", err)
+	}
+	q.last = block
+	return block, nil
+}
+
+// waitForBlock polls for a block until it is available. Flashblock receipts
+// are visible before the block is sealed, so the block may lag behind them.
+func (q *blockQueue) waitForBlock(blockNumber uint64) (*types.Block, error) {
+	deadline := q.clock.blockTimestamp(blockNumber).Add(blockWaitTimeout)
+	for {
+		block, err := q.block(blockNumber)
+		if err == nil || time.Now().After(deadline) {
+			return block, err
+		}
+		time.Sleep(time.Duration(q.pollingIntervalMs) * time.Millisecond)
+	}
+}
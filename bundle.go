@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	bundleStatusIncluded    = "included"
+	bundleStatusPartial     = "partial"
+	bundleStatusNotIncluded = "not_included"
+	bundleStatusFailed      = "failed"
+)
+
+// bundleWaitBlocks is how many blocks past the target we wait for bundle
+// transactions to show up before giving up on them.
+const bundleWaitBlocks = 5
+
+type bundleResult struct {
+	BundleHash      string
+	TargetBlock     uint64
+	SentAt          time.Time
+	IncludedInBlock uint64
+	InclusionDelay  time.Duration
+	TxnHashes       []string
+	Atomic          bool
+	Status          string
+}
+
+type Bundle struct {
+	Txs                 [][]byte      `json:"txs"`                           // Raw transaction bytes
+	BlockNumber         uint64        `json:"blockNumber"`                   // Target block number
+	FlashblockNumberMin *uint64       `json:"flashblockNumberMin,omitempty"` // Optional: minimum flashblock number
+	FlashblockNumberMax *uint64       `json:"flashblockNumberMax,omitempty"` // Optional: maximum flashblock number
+	MinTimestamp        *uint64       `json:"minTimestamp,omitempty"`        // Optional: minimum timestamp
+	MaxTimestamp        *uint64       `json:"maxTimestamp,omitempty"`        // Optional: maximum timestamp
+	RevertingTxHashes   []common.Hash `json:"revertingTxHashes"`             // Transaction hashes that can revert
+	ReplacementUuid     *string       `json:"replacementUuid,omitempty"`     // Optional: replacement UUID
+	DroppingTxHashes    []common.Hash `json:"droppingTxHashes"`              // Transaction hashes to drop
+}
+
+func sendBundle(client *ethclient.Client, signedTxs []*types.Transaction, targetBlockNumber uint64) (string, error) {
+	// Convert transactions to raw transaction bytes and collect hashes
+	var txsBytes [][]byte
+	var txHashes []common.Hash
+	for _, tx := range signedTxs {
+		rawTx, err := tx.MarshalBinary()
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal transaction: %v", err)
+		}
+		txsBytes = append(txsBytes, rawTx)
+		txHashes = append(txHashes, tx.Hash())
+	}
+
+	// Create bundle structure matching Base TIPS format
+	bundle := Bundle{
+		Txs:               txsBytes,
+		BlockNumber:       targetBlockNumber,
+		RevertingTxHashes: txHashes,        // All transaction hashes must be in reverting_tx_hashes
+		DroppingTxHashes:  []common.Hash{}, // Empty array if no dropping txs
+	}
+
+	// Send bundle via RPC call
+	var bundleHash string
+	err := client.Client().CallContext(context.Background(), &bundleHash, "eth_sendBundle", bundle)
+	if err != nil {
+		return "", fmt.Errorf("unable to send bundle: %v", err)
+	}
+
+	log.Printf("Bundle sent successfully with hash: %s", bundleHash)
+	return bundleHash, nil
+}
+
+func createAndSendBundle(signer types.Signer, privateKey *ecdsa.PrivateKey, fromAddress common.Address, sc *scenario, client *ethclient.Client, numTxs int, pollingIntervalMs int) (bundleResult, error) {
+	result := bundleResult{Status: bundleStatusFailed}
+
+	// Get current block number for targeting
+	currentBlock, err := client.BlockNumber(context.Background())
+	if err != nil {
+		return result, fmt.Errorf("unable to get current block number: %v", err)
+	}
+
+	// Target the next block
+	targetBlock := currentBlock + 1
+	result.TargetBlock = targetBlock
+
+	// Get base nonce
+	baseNonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	if err != nil {
+		return result, fmt.Errorf("unable to get nonce: %v", err)
+	}
+
+	// Create multiple signed transactions for the bundle
+	var signedTxs []*types.Transaction
+	for i := 0; i < numTxs; i++ {
+		nonce := baseNonce + uint64(i) // Sequential nonces
+		signedTx, err := createTx(signer, privateKey, sc, client, nonce)
+		if err != nil {
+			return result, fmt.Errorf("unable to create transaction %d: %v", i, err)
+		}
+
+		signedTxs = append(signedTxs, signedTx)
+		result.TxnHashes = append(result.TxnHashes, signedTx.Hash().Hex())
+		log.Printf("Created transaction %d with nonce %d, hash: %s", i, nonce, signedTx.Hash().Hex())
+	}
+
+	// Send the bundle
+	result.SentAt = time.Now()
+	bundleHash, err := sendBundle(client, signedTxs, targetBlock)
+	if err != nil {
+		return result, fmt.Errorf("failed to send bundle: %v", err)
+	}
+	result.BundleHash = bundleHash
+
+	log.Printf("Bundle sent with hash: %s, targeting block: %d", bundleHash, targetBlock)
+
+	waitForBundle(client, signedTxs, &result, pollingIntervalMs)
+	log.Printf("Bundle %s status: %s, included in block: %d, atomic: %v", bundleHash, result.Status, result.IncludedInBlock, result.Atomic)
+	return result, nil
+}
+
+// waitForBundle polls for the bundle's receipts until all of them are found,
+// the chain has moved bundleWaitBlocks past the target block, or a minute has
+// passed. The bundle is atomic when every transaction landed in the same block,
+// back to back and in bundle order.
+func waitForBundle(client *ethclient.Client, signedTxs []*types.Transaction, result *bundleResult, pollingIntervalMs int) {
+	receipts := make([]*types.Receipt, len(signedTxs))
+	found := 0
+
+	for found < len(signedTxs) {
+		for i, tx := range signedTxs {
+			if receipts[i] != nil {
+				continue
+			}
+			receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+			if err == nil {
+				receipts[i] = receipt
+				found++
+				if found == 1 {
+					result.InclusionDelay = time.Since(result.SentAt)
+				}
+			}
+		}
+		if found == len(signedTxs) {
+			break
+		}
+
+		blockNumber, err := client.BlockNumber(context.Background())
+		if err == nil && blockNumber > result.TargetBlock+bundleWaitBlocks {
+			break
+		}
+		if time.Since(result.SentAt) > time.Minute {
+			break
+		}
+		time.Sleep(time.Duration(pollingIntervalMs) * time.Millisecond)
+	}
+
+	switch {
+	case found == 0:
+		result.Status = bundleStatusNotIncluded
+		return
+	case found < len(signedTxs):
+		result.Status = bundleStatusPartial
+	default:
+		result.Status = bundleStatusIncluded
+	}
+
+	for _, receipt := range receipts {
+		if receipt != nil {
+			result.IncludedInBlock = receipt.BlockNumber.Uint64()
+			break
+		}
+	}
+
+	result.Atomic = found == len(signedTxs)
+	for i := 1; i < len(receipts) && result.Atomic; i++ {
+		sameBlock := receipts[i].BlockNumber.Cmp(receipts[0].BlockNumber) == 0
+		consecutive := receipts[i].TransactionIndex == receipts[i-1].TransactionIndex+1
+		result.Atomic = sameBlock && consecutive
+	}
+}
+
+func writeBundlesToFile(filename string, data []bundleResult) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"bundle_hash", "target_block", "sent_at", "included_in_block", "inclusion_delay_ms", "txn_hashes", "atomic", "status"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, d := range data {
+		row := []string{
+			d.BundleHash,
+			strconv.FormatUint(d.TargetBlock, 10),
+			wallClock(d.SentAt).String(),
+			strconv.FormatUint(d.IncludedInBlock, 10),
+			strconv.FormatInt(d.InclusionDelay.Milliseconds(), 10),
+			strings.Join(d.TxnHashes, ";"),
+			strconv.FormatBool(d.Atomic),
+			d.Status,
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
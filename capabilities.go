@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// capabilities records which optional RPCs an endpoint supports.
+type capabilities struct {
+	SendRawTransactionSync bool
+	SendBundle             bool
+}
+
+func (c capabilities) String() string {
+	return "eth_sendRawTransactionSync=" + supportedString(c.SendRawTransactionSync) +
+		" eth_sendBundle=" + supportedString(c.SendBundle)
+}
+
+func supportedString(supported bool) string {
+	if supported {
+		return "supported"
+	}
+	return "unsupported"
+}
+
+// probeCapabilities calls each optional method with deliberately invalid
+// parameters. Endpoints that know the method reject the parameters, endpoints
+// that don't reject the method itself.
+func probeCapabilities(client *ethclient.Client) capabilities {
+	return capabilities{
+		SendRawTransactionSync: probeMethod(client, "eth_sendRawTransactionSync", "0x"),
+		SendBundle:             probeMethod(client, "eth_sendBundle", struct{}{}),
+	}
+}
+
+func probeMethod(client *ethclient.Client, method string, args ...interface{}) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var result interface{}
+	err := client.Client().CallContext(ctx, &result, method, args...)
+	if err == nil {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusNotFound || httpErr.StatusCode == http.StatusMethodNotAllowed) {
+		return false
+	}
+
+	// Gateways don't always use the standard error code
+	message := strings.ToLower(err.Error())
+	for _, hint := range []string{"method not found", "does not exist", "not supported", "not available", "unsupported method"} {
+		if strings.Contains(message, hint) {
+			return false
+		}
+	}
+
+	if rpcErr == nil && httpErr.StatusCode == 0 {
+		// Transport failures say nothing about the method, assume it works
+		log.Printf("Unable to probe %s, assuming it is supported: %v", method, err)
+	}
+	return true
+}
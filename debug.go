@@ -0,0 +1,46 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+)
+
+// startDebugServer serves pprof under /debug/pprof/ and runtime metrics under
+// /debug/vars, so long runs can confirm the tool itself isn't the bottleneck.
+// It should only be bound to a local address.
+func startDebugServer(addr string) {
+	expvar.Publish("runtime", expvar.Func(func() interface{} {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return map[string]interface{}{
+			"goroutines":      runtime.NumGoroutine(),
+			"heap_alloc":      m.HeapAlloc,
+			"heap_objects":    m.HeapObjects,
+			"heap_sys":        m.HeapSys,
+			"num_gc":          m.NumGC,
+			"last_gc_pause":   m.PauseNs[(m.NumGC+255)%256],
+			"gc_cpu_fraction": m.GCCPUFraction,
+		}
+	}))
+
+	go func() {
+		log.Printf("Debug server listening on %s", addr)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Printf("Debug server stopped: %v", err)
+		}
+	}()
+}
+
+// publishResultWriter exposes how many rows are waiting to be written, which
+// grows when the output can't keep up with the send rate.
+func publishResultWriter(name string, w *resultWriter) {
+	expvar.Publish("result_buffer_"+name, expvar.Func(func() interface{} {
+		return map[string]int{
+			"pending":  len(w.rows),
+			"capacity": cap(w.rows),
+		}
+	}))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// efficiency pairs a lane's median latency with its median cost, and prices
+// the latency it saves relative to a baseline lane.
+type efficiency struct {
+	Lane         string
+	Count        int
+	LatencyP50   time.Duration
+	CostP50      *big.Int
+	Saved        time.Duration // relative to the baseline, negative when slower
+	ExtraCost    *big.Int      // relative to the baseline, nil without one
+	CostPer100ms *big.Int      // extra cost per 100ms saved, nil when nothing is saved
+}
+
+// receiptFee is the total fee paid for a transaction, including the L1 data fee
+// on OP stack chains.
+func receiptFee(receipt *types.Receipt) *big.Int {
+	if receipt.EffectiveGasPrice == nil {
+		return nil
+	}
+
+	fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	if receipt.L1Fee != nil {
+		fee.Add(fee, receipt.L1Fee)
+	}
+	return fee
+}
+
+func medianBig(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]*big.Int(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return sorted[(len(sorted)-1)/2]
+}
+
+// computeEfficiency builds the cost-benefit table for every lane with fee data.
+func computeEfficiency(timings []stats, baselineLane string) []efficiency {
+	delays := make(map[string][]time.Duration)
+	costs := make(map[string][]*big.Int)
+	var lanes []string
+	for _, t := range timings {
+		if t.Status != statusIncluded || t.FeePaid == nil {
+			continue
+		}
+		if _, ok := delays[t.Lane]; !ok {
+			lanes = append(lanes, t.Lane)
+		}
+		delays[t.Lane] = append(delays[t.Lane], t.InclusionDelay)
+		costs[t.Lane] = append(costs[t.Lane], t.FeePaid)
+	}
+	sort.Strings(lanes)
+
+	var efficiencies []efficiency
+	for _, l := range lanes {
+		efficiencies = append(efficiencies, efficiency{
+			Lane:       l,
+			Count:      len(delays[l]),
+			LatencyP50: summarize("", l, delays[l]).P50,
+			CostP50:    medianBig(costs[l]),
+		})
+	}
+
+	var baseline *efficiency
+	for i := range efficiencies {
+		if efficiencies[i].Lane == baselineLane {
+			baseline = &efficiencies[i]
+		}
+	}
+	if baseline == nil {
+		log.Printf("NOTICE: efficiency baseline lane %s has no included rows, set EFFICIENCY_BASELINE_LANE to one of %v", baselineLane, lanes)
+		return efficiencies
+	}
+
+	for i := range efficiencies {
+		e := &efficiencies[i]
+		e.Saved = baseline.LatencyP50 - e.LatencyP50
+		e.ExtraCost = new(big.Int).Sub(e.CostP50, baseline.CostP50)
+		if e.Saved > 0 {
+			e.CostPer100ms = new(big.Int).Mul(e.ExtraCost, big.NewInt(int64(100*time.Millisecond)))
+			e.CostPer100ms.Quo(e.CostPer100ms, big.NewInt(int64(e.Saved)))
+		}
+	}
+	return efficiencies
+}
+
+func logEfficiency(efficiencies []efficiency) {
+	for _, e := range efficiencies {
+		log.Printf("lane=%s p50=%dms cost_p50=%s wei saved=%dms cost_per_100ms_saved=%s wei", e.Lane, e.LatencyP50.Milliseconds(), formatBig(e.CostP50), e.Saved.Milliseconds(), formatBig(e.CostPer100ms))
+	}
+}
+
+func writeEfficiency(filename string, efficiencies []efficiency) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"lane", "count", "latency_p50_ms", "cost_p50_wei", "saved_ms", "extra_cost_wei", "cost_per_100ms_saved_wei"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, e := range efficiencies {
+		row := []string{
+			e.Lane,
+			strconv.Itoa(e.Count),
+			strconv.FormatInt(e.LatencyP50.Milliseconds(), 10),
+			formatBig(e.CostP50),
+			strconv.FormatInt(e.Saved.Milliseconds(), 10),
+			formatBig(e.ExtraCost),
+			formatBig(e.CostPer100ms),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// blockClock maps block numbers to wall-clock build windows. Block timestamps on
+// OP stack chains advance by a fixed block time, so a single anchor header is
+// enough to place every later block without fetching it.
+type blockClock struct {
+	anchorNumber       uint64
+	anchorTime         time.Time
+	blockTime          time.Duration
+	flashblockInterval time.Duration
+}
+
+func newBlockClock(client *ethclient.Client, blockTime time.Duration, flashblockInterval time.Duration) (*blockClock, error) {
+	header, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get latest header: %v", err)
+	}
+
+	return &blockClock{
+		anchorNumber:       header.Number.Uint64(),
+		anchorTime:         time.Unix(int64(header.Time), 0),
+		blockTime:          blockTime,
+		flashblockInterval: flashblockInterval,
+	}, nil
+}
+
+func (c *blockClock) flashblocksPerBlock() int {
+	return int(c.blockTime / c.flashblockInterval)
+}
+
+// blockTimestamp returns the timestamp of the given block, at which point the
+// block is sealed. Building starts one block time earlier.
+func (c *blockClock) blockTimestamp(blockNumber uint64) time.Time {
+	offset := (int64(blockNumber) - int64(c.anchorNumber)) * int64(c.blockTime)
+	return c.anchorTime.Add(time.Duration(offset))
+}
+
+// flashblockIndex estimates which flashblock (1-based) of the given block a
+// transaction landed in, based on when its receipt was first observed. Receipts
+// are polled, so the estimate can lag by up to one polling interval.
+func (c *blockClock) flashblockIndex(blockNumber uint64, observedAt time.Time) int {
+	buildStart := c.blockTimestamp(blockNumber).Add(-c.blockTime)
+	index := int(observedAt.Sub(buildStart)/c.flashblockInterval) + 1
+
+	if index < 1 {
+		return 1
+	}
+	if maxIndex := c.flashblocksPerBlock(); index > maxIndex {
+		return maxIndex
+	}
+	return index
+}
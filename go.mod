@@ -5,6 +5,7 @@ go 1.24
 require (
 	github.com/ethereum/go-ethereum v1.15.7
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.17.11
 )
 
 require (
@@ -23,7 +24,6 @@ require (
 	github.com/go-ole/go-ole v1.3.0 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/holiman/uint256 v1.3.2 // indirect
-	github.com/klauspost/compress v1.17.11 // indirect
 	github.com/mmcloughlin/addchain v0.4.0 // indirect
 	github.com/naoina/go-stringutil v0.1.0 // indirect
 	github.com/naoina/toml v0.1.2-0.20170918210437-9fafd6967416 // indirect
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// checkScenario estimates gas for what the run will send, so a recipient that
+// rejects plain transfers, a contract call that reverts or a gas limit below
+// what the call needs is caught before every transaction of the run fails the
+// same way. Transfers are checked against every recipient; contract calls
+// once, on a copy of the scenario so the run's sequence numbers are untouched.
+func checkScenario(client *ethclient.Client, sc *scenario) error {
+	if sc.method == nil {
+		for _, recipient := range sc.recipients {
+			msg := ethereum.CallMsg{From: sc.from, To: &recipient, Value: sc.value}
+			gasLimit := sc.gasLimit
+			if sc.memo {
+				msg.Data = memo(0)
+				gasLimit = transferGas(msg.Data)
+			}
+			estimate, err := client.EstimateGas(context.Background(), msg)
+			if err != nil {
+				return fmt.Errorf("transfers to %s fail: %v; if it is a contract without a payable receive function, point TO_ADDRESS or TO_ADDRESSES at an account that accepts transfers", recipient.Hex(), err)
+			}
+			if estimate > gasLimit {
+				return fmt.Errorf("transfers to %s need %d gas but are sent with %d and would run out of gas; it is likely a contract, point TO_ADDRESS or TO_ADDRESSES at an account without code", recipient.Hex(), estimate, gasLimit)
+			}
+		}
+		return nil
+	}
+
+	preview := *sc
+	payload, err := preview.next()
+	if err != nil {
+		return fmt.Errorf("unable to build payload: %v", err)
+	}
+
+	msg := ethereum.CallMsg{From: sc.from, To: &payload.To, Value: payload.Value, Data: payload.Data}
+	estimate, err := client.EstimateGas(context.Background(), msg)
+	if err != nil {
+		return fmt.Errorf("calling %s on %s fails: %v; check CONTRACT_ARGS, CONTRACT_VALUE_WEI and that the sender is allowed to call it", sc.method.Name, payload.To.Hex(), err)
+	}
+	// Without CONTRACT_GAS_LIMIT every transaction is estimated on its own
+	if payload.Gas > 0 && estimate > payload.Gas {
+		return fmt.Errorf("calling %s on %s needs %d gas, above CONTRACT_GAS_LIMIT=%d; raise it or unset it to estimate each transaction", sc.method.Name, payload.To.Hex(), estimate, payload.Gas)
+	}
+	return nil
+}
+
+// checkPending makes sure the sender's queued transactions plus the most the
+// run has in flight at once stay within the node's per-sender limit, since the
+// node rejects submissions beyond it.
+func checkPending(client *ethclient.Client, fromAddress common.Address, inFlight int, maxPending int) error {
+	pendingNonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	if err != nil {
+		return fmt.Errorf("unable to get pending nonce: %v", err)
+	}
+
+	nonce, err := client.NonceAt(context.Background(), fromAddress, nil)
+	if err != nil {
+		return fmt.Errorf("unable to get nonce: %v", err)
+	}
+
+	pending := int(pendingNonce - nonce)
+	if pendingNonce < nonce {
+		pending = 0
+	}
+	if pending+inFlight > maxPending {
+		return fmt.Errorf("%s already has %d pending transactions and the run sends up to %d at once, above MAX_PENDING_TXS=%d; wait for them to be included, replace them, or raise MAX_PENDING_TXS if the node allows more", fromAddress.Hex(), pending, inFlight, maxPending)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// heatmapReservoirSize caps the delays kept per cell, so a heatmap fed for
+// days stays bounded in memory while its percentiles remain representative.
+const heatmapReservoirSize = 1000
+
+type heatmapKey struct {
+	Lane    string
+	Weekday time.Weekday
+	Hour    int
+}
+
+type heatmapBucket struct {
+	count  int
+	delays []time.Duration
+}
+
+// heatmap buckets inclusion latency by lane, weekday and hour of day (UTC) of
+// submission. Unlike the result window it sees every row of the run.
+type heatmap struct {
+	buckets map[heatmapKey]*heatmapBucket
+}
+
+type heatmapCell struct {
+	Key     heatmapKey
+	Summary latencySummary
+}
+
+func newHeatmap() *heatmap {
+	return &heatmap{buckets: make(map[heatmapKey]*heatmapBucket)}
+}
+
+// add records an included row, keeping a uniform sample of each cell's delays
+// once it holds more than heatmapReservoirSize of them.
+func (h *heatmap) add(row stats) {
+	if row.Status != statusIncluded {
+		return
+	}
+
+	sentAt := wallClock(row.SentAt).UTC()
+	key := heatmapKey{Lane: row.Lane, Weekday: sentAt.Weekday(), Hour: sentAt.Hour()}
+	bucket, ok := h.buckets[key]
+	if !ok {
+		bucket = &heatmapBucket{}
+		h.buckets[key] = bucket
+	}
+
+	bucket.count++
+	if len(bucket.delays) < heatmapReservoirSize {
+		bucket.delays = append(bucket.delays, row.InclusionDelay)
+	} else if i := rand.Intn(bucket.count); i < heatmapReservoirSize {
+		bucket.delays[i] = row.InclusionDelay
+	}
+}
+
+// cells returns the populated cells ordered by lane, weekday and hour. Count is
+// the number of rows seen, percentiles come from the sampled delays.
+func (h *heatmap) cells() []heatmapCell {
+	var cells []heatmapCell
+	for key, bucket := range h.buckets {
+		summary := summarize("heatmap", key.Lane, bucket.delays)
+		summary.Count = bucket.count
+		cells = append(cells, heatmapCell{Key: key, Summary: summary})
+	}
+
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i].Key, cells[j].Key
+		if a.Lane != b.Lane {
+			return a.Lane < b.Lane
+		}
+		if a.Weekday != b.Weekday {
+			return a.Weekday < b.Weekday
+		}
+		return a.Hour < b.Hour
+	})
+	return cells
+}
+
+func writeHeatmap(filename string, cells []heatmapCell) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"lane", "weekday", "hour_utc", "count", "p50_ms", "p90_ms", "p99_ms"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, c := range cells {
+		row := []string{
+			c.Key.Lane,
+			c.Key.Weekday.String(),
+			strconv.Itoa(c.Key.Hour),
+			strconv.Itoa(c.Summary.Count),
+			strconv.FormatInt(c.Summary.P50.Milliseconds(), 10),
+			strconv.FormatInt(c.Summary.P90.Milliseconds(), 10),
+			strconv.FormatInt(c.Summary.P99.Milliseconds(), 10),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
-	"encoding/csv"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"math/big"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -21,24 +26,74 @@ import (
 )
 
 type stats struct {
-	SentAt          time.Time
-	TxnHash         string
-	IncludedInBlock uint64
-	InclusionDelay  time.Duration
+	Phase                 string
+	TraceID               string // sent as a header on the submission request
+	Sample                int
+	Retry                 bool
+	SentAt                time.Time
+	TxnHash               string
+	IncludedInBlock       uint64
+	IncludedAt            time.Time
+	InclusionDelay        time.Duration // monotonic
+	WallInclusionDelay    time.Duration
+	ClockJump             bool
+	FlashblockIndex       int
+	Status                string
+	BaseFee               *big.Int
+	EffectiveTip          *big.Int
+	FeePaid               *big.Int
+	BlockFullness         float64 // gas used over gas limit of the inclusion block
+	Builder               string
+	Lane                  string
+	Source                string
+	PushInclusionDelay    time.Duration // zero when no notification was received
+	To                    string
+	Value                 *big.Int
+	EndpointFailureStreak int
+	EndpointUnreachable   time.Duration
+	tx                    *types.Transaction // for the block columns, filled in once the block is sealed
 }
 
-type Bundle struct {
-	Txs                 [][]byte      `json:"txs"`                           // Raw transaction bytes
-	BlockNumber         uint64        `json:"blockNumber"`                   // Target block number
-	FlashblockNumberMin *uint64       `json:"flashblockNumberMin,omitempty"` // Optional: minimum flashblock number
-	FlashblockNumberMax *uint64       `json:"flashblockNumberMax,omitempty"` // Optional: maximum flashblock number
-	MinTimestamp        *uint64       `json:"minTimestamp,omitempty"`        // Optional: minimum timestamp
-	MaxTimestamp        *uint64       `json:"maxTimestamp,omitempty"`        // Optional: maximum timestamp
-	RevertingTxHashes   []common.Hash `json:"revertingTxHashes"`             // Transaction hashes that can revert
-	ReplacementUuid     *string       `json:"replacementUuid,omitempty"`     // Optional: replacement UUID
-	DroppingTxHashes    []common.Hash `json:"droppingTxHashes"`              // Transaction hashes to drop
+// lane is a route for submitting transactions. Receipts are polled on observer,
+// the phase's own endpoint, so lanes are observed the same way. The source is
+// the local IP or interface the lane sends from, empty for the default route.
+type lane struct {
+	name         string
+	source       string
+	submitter    *ethclient.Client
+	observer     *ethclient.Client
+	availability *endpointAvailability
+	sync         bool
+	traceHeader  string
+	push         *inclusionStream
 }
 
+// sample is one transaction slot of a phase. A retry reruns a sample that
+// failed or expired, keeping its index so the rows can be matched up.
+type sample struct {
+	index int
+	retry bool
+}
+
+func newSamples(n int) []sample {
+	samples := make([]sample, n)
+	for i := range samples {
+		samples[i].index = i
+	}
+	return samples
+}
+
+const (
+	phaseFlashblocks = "flashblocks"
+	phaseBase        = "base"
+)
+
+const (
+	statusIncluded = "included"
+	statusExpired  = "expired"
+	statusFailed   = "failed"
+)
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -50,6 +105,50 @@ func main() {
 		log.Fatal("REGION environment variable not set")
 	}
 
+	blockTimeMs := 2000
+	if blockTimeEnv := os.Getenv("BLOCK_TIME_MS"); blockTimeEnv != "" {
+		if parsed, err := strconv.Atoi(blockTimeEnv); err == nil {
+			blockTimeMs = parsed
+		}
+	}
+
+	flashblockIntervalMs := 200
+	if intervalEnv := os.Getenv("FLASHBLOCK_INTERVAL_MS"); intervalEnv != "" {
+		if parsed, err := strconv.Atoi(intervalEnv); err == nil {
+			flashblockIntervalMs = parsed
+		}
+	}
+
+	if blockTimeMs <= 0 || flashblockIntervalMs <= 0 {
+		log.Fatal("BLOCK_TIME_MS and FLASHBLOCK_INTERVAL_MS must be positive")
+	}
+
+	// Replay re-times a previous results file against an archive endpoint
+	// instead of sending transactions
+	if replayFile := os.Getenv("REPLAY_FILE"); replayFile != "" {
+		archiveUrl := os.Getenv("ARCHIVE_URL")
+		if archiveUrl == "" {
+			archiveUrl = os.Getenv("BASE_URL")
+		}
+		if archiveUrl == "" {
+			log.Fatal("ARCHIVE_URL environment variable not set")
+		}
+
+		archiveClient, err := ethclient.Dial(archiveUrl)
+		if err != nil {
+			log.Fatalf("Failed to connect to the archive endpoint: %v", err)
+		}
+
+		replayName := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(replayFile), ".gz"), ".zst")
+		replayOutput := filepath.Join(filepath.Dir(replayFile), "replayed-"+replayName)
+		replayed, err := replayResults(archiveClient, time.Duration(blockTimeMs)*time.Millisecond, time.Duration(flashblockIntervalMs)*time.Millisecond, replayFile, replayOutput)
+		if err != nil {
+			log.Fatalf("Failed to replay %s: %v", replayFile, err)
+		}
+		log.Printf("Replayed %d rows from %s into %s", replayed, replayFile, replayOutput)
+		return
+	}
+
 	key := os.Getenv("PRIVATE_KEY")
 	if key == "" {
 		log.Fatal("PRIVATE_KEY environment variable not set")
@@ -102,16 +201,267 @@ func main() {
 		}
 	}
 
-	flashblocksClient, err := ethclient.Dial(flashblocksUrl)
-	if err != nil {
-		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+	rttSamples := 20
+	if rttSamplesEnv := os.Getenv("RTT_SAMPLES"); rttSamplesEnv != "" {
+		if parsed, err := strconv.Atoi(rttSamplesEnv); err == nil {
+			rttSamples = parsed
+		}
 	}
 
-	baseClient, err := ethclient.Dial(baseUrl)
-	if err != nil {
-		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+	// Failed and expired samples are run once more at the end of each phase,
+	// up to this many per phase, and written as retries of the same sample
+	retryLimit := 0
+	if retryEnv := os.Getenv("RETRY_FAILED_SAMPLES"); retryEnv != "" {
+		if parsed, err := strconv.Atoi(retryEnv); err == nil {
+			retryLimit = parsed
+		}
+	}
+
+	// Pre-run checks that the scenario doesn't revert and the sender has room
+	// in the node's per-sender pending limit, which defaults to 16 on geth
+	skipGuardrails := os.Getenv("SKIP_GUARDRAILS") == "true"
+
+	maxPendingTxs := 16
+	if pendingEnv := os.Getenv("MAX_PENDING_TXS"); pendingEnv != "" {
+		if parsed, err := strconv.Atoi(pendingEnv); err == nil {
+			maxPendingTxs = parsed
+		}
+	}
+
+	// Simulation mode only times eth_call of the scenario payload against
+	// every endpoint, sending nothing
+	simulationMode := os.Getenv("SIMULATION_MODE") == "true"
+
+	simulationRate := 10.0
+	if rateEnv := os.Getenv("SIMULATION_RATE_PER_SEC"); rateEnv != "" {
+		if parsed, err := strconv.ParseFloat(rateEnv, 64); err == nil && parsed > 0 {
+			simulationRate = parsed
+		}
+	}
+
+	// Soak mode repeats the test until interrupted, keeping only a bounded
+	// window of rows in memory for analysis
+	soakMode := os.Getenv("SOAK_MODE") == "true"
+
+	resultBufferSize := 1000
+	if bufferEnv := os.Getenv("RESULT_BUFFER_SIZE"); bufferEnv != "" {
+		if parsed, err := strconv.Atoi(bufferEnv); err == nil && parsed >= 0 {
+			resultBufferSize = parsed
+		}
+	}
+
+	resultFlushIntervalMs := 5000
+	if flushEnv := os.Getenv("RESULT_FLUSH_INTERVAL_MS"); flushEnv != "" {
+		if parsed, err := strconv.Atoi(flushEnv); err == nil && parsed > 0 {
+			resultFlushIntervalMs = parsed
+		}
+	}
+	resultFlushInterval := time.Duration(resultFlushIntervalMs) * time.Millisecond
+
+	// Results can be compressed with gzip or zstd and rotated into numbered
+	// files by row count and/or age, keeping daemon runs within small disks
+	resultCompression := os.Getenv("RESULT_COMPRESSION")
+
+	resultRotateRows := 0
+	if rotateEnv := os.Getenv("RESULT_ROTATE_ROWS"); rotateEnv != "" {
+		if parsed, err := strconv.Atoi(rotateEnv); err == nil {
+			resultRotateRows = parsed
+		}
+	}
+
+	resultRotateIntervalMs := 0
+	if rotateEnv := os.Getenv("RESULT_ROTATE_INTERVAL_MS"); rotateEnv != "" {
+		if parsed, err := strconv.Atoi(rotateEnv); err == nil {
+			resultRotateIntervalMs = parsed
+		}
+	}
+	resultRotateInterval := time.Duration(resultRotateIntervalMs) * time.Millisecond
+
+	resultWindowSize := 0
+	if soakMode {
+		resultWindowSize = 10000
+	}
+	if windowEnv := os.Getenv("RESULT_WINDOW"); windowEnv != "" {
+		if parsed, err := strconv.Atoi(windowEnv); err == nil {
+			resultWindowSize = parsed
+		}
+	}
+
+	// Lane that cost per latency saved is measured against, the first base
+	// lane unless set
+	efficiencyBaselineLane := os.Getenv("EFFICIENCY_BASELINE_LANE")
+
+	// Optional summary or results files of an earlier run that this run's
+	// percentiles are compared against, exiting non-zero on a regression
+	baselineFile := os.Getenv("BASELINE_FILE")
+	var baselineSummaries []latencySummary
+	if baselineFile != "" {
+		baselineSummaries, err = loadBaseline(baselineFile)
+		if err != nil {
+			log.Fatalf("Failed to load baseline: %v", err)
+		}
+		log.Printf("Loaded %d baseline summaries from %s", len(baselineSummaries), baselineFile)
+	}
+
+	regressionTolerancePct := 10.0
+	if toleranceEnv := os.Getenv("REGRESSION_TOLERANCE_PCT"); toleranceEnv != "" {
+		if parsed, err := strconv.ParseFloat(toleranceEnv, 64); err == nil {
+			regressionTolerancePct = parsed
+		}
+	}
+
+	regressionToleranceMs := 0
+	if toleranceEnv := os.Getenv("REGRESSION_TOLERANCE_MS"); toleranceEnv != "" {
+		if parsed, err := strconv.Atoi(toleranceEnv); err == nil {
+			regressionToleranceMs = parsed
+		}
+	}
+
+	// Local address for pprof and runtime metrics, meant for soak runs
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if debugAddr != "" {
+		startDebugServer(debugAddr)
 	}
 
+	clockJumpToleranceMs := 50
+	if toleranceEnv := os.Getenv("CLOCK_JUMP_TOLERANCE_MS"); toleranceEnv != "" {
+		if parsed, err := strconv.Atoi(toleranceEnv); err == nil {
+			clockJumpToleranceMs = parsed
+		}
+	}
+	clockJumpTolerance := time.Duration(clockJumpToleranceMs) * time.Millisecond
+
+	// Transactions not included within the deadline are replaced by a
+	// zero-value self transfer and recorded as expired. Zero disables it.
+	txDeadlineMs := 0
+	if deadlineEnv := os.Getenv("TX_DEADLINE_MS"); deadlineEnv != "" {
+		if parsed, err := strconv.Atoi(deadlineEnv); err == nil {
+			txDeadlineMs = parsed
+		}
+	}
+	txDeadline := time.Duration(txDeadlineMs) * time.Millisecond
+
+	availability := &availabilityTracker{}
+
+	// Optional local IPs or interfaces to send from, one lane per source, so a
+	// multi-homed host can compare several vantage points in one run
+	var flashblockLanes []lane
+	for _, source := range parseSources(os.Getenv("FLASHBLOCKS_SOURCES")) {
+		name := laneName("flashblocks", source)
+		client, err := dialEndpoint(flashblocksUrl, source, availability.endpoint(name))
+		if err != nil {
+			log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+		}
+		flashblockLanes = append(flashblockLanes, lane{name: name, source: source, submitter: client, observer: client, availability: availability.endpoint(name)})
+	}
+	flashblocksClient := flashblockLanes[0].submitter
+
+	var baseLanes []lane
+	for _, source := range parseSources(os.Getenv("BASE_SOURCES")) {
+		name := laneName("base", source)
+		client, err := dialEndpoint(baseUrl, source, availability.endpoint(name))
+		if err != nil {
+			log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+		}
+		// Sync sending is currently not supported on non-flashblock endpoints
+		baseLanes = append(baseLanes, lane{name: name, source: source, submitter: client, observer: client, availability: availability.endpoint(name)})
+	}
+	baseClient := baseLanes[0].submitter
+	if efficiencyBaselineLane == "" {
+		efficiencyBaselineLane = baseLanes[0].name
+	}
+
+	// Optional relay or priority endpoint that flashblock submissions alternate with
+	gatewayUrl := os.Getenv("GATEWAY_URL")
+	gatewayName := os.Getenv("GATEWAY_NAME")
+	if gatewayName == "" {
+		gatewayName = "gateway"
+	}
+
+	var gatewayLanes []lane
+	if gatewayUrl != "" {
+		for _, source := range parseSources(os.Getenv("GATEWAY_SOURCES")) {
+			name := laneName(gatewayName, source)
+			client, err := dialEndpoint(gatewayUrl, source, availability.endpoint(name))
+			if err != nil {
+				log.Fatalf("Failed to connect to the gateway: %v", err)
+			}
+
+			// Receipts are observed on flashblocks from the same source when
+			// there is one
+			observer := flashblocksClient
+			for _, ln := range flashblockLanes {
+				if ln.source == source {
+					observer = ln.submitter
+				}
+			}
+			gatewayLanes = append(gatewayLanes, lane{name: name, source: source, submitter: client, observer: observer, availability: availability.endpoint(name)})
+		}
+	}
+
+	caps := probeCapabilities(flashblocksClient)
+	log.Printf("Flashblocks endpoint capabilities: %v", caps)
+
+	if sendTxnSync && !caps.SendRawTransactionSync {
+		log.Printf("NOTICE: eth_sendRawTransactionSync is not supported by the flashblocks endpoint, falling back to async sending")
+		sendTxnSync = false
+	}
+
+	if gatewayLanes != nil {
+		gatewayCaps := probeCapabilities(gatewayLanes[0].submitter)
+		log.Printf("Gateway %s capabilities: %v", gatewayName, gatewayCaps)
+
+		// Lanes are only comparable when they submit the same way
+		if sendTxnSync && !gatewayCaps.SendRawTransactionSync {
+			log.Printf("NOTICE: eth_sendRawTransactionSync is not supported by gateway %s, falling back to async sending", gatewayName)
+			sendTxnSync = false
+		}
+	}
+
+	if runBundleTest && !caps.SendBundle {
+		log.Printf("NOTICE: eth_sendBundle is not supported by the flashblocks endpoint, skipping bundle test")
+		runBundleTest = false
+	}
+
+	// Pacing between transactions and phases, tune these for chains with a
+	// different block time. Flashblock defaults depend on whether sending is
+	// sync, so they are resolved once that is settled.
+	flashblockPacingMinMs, flashblockPacingMaxMs := 600, 1200
+	if sendTxnSync {
+		flashblockPacingMinMs, flashblockPacingMaxMs = 200, 400
+	}
+	if pacingEnv := os.Getenv("FLASHBLOCK_PACING_MIN_MS"); pacingEnv != "" {
+		if parsed, err := strconv.Atoi(pacingEnv); err == nil {
+			flashblockPacingMinMs = parsed
+		}
+	}
+	if pacingEnv := os.Getenv("FLASHBLOCK_PACING_MAX_MS"); pacingEnv != "" {
+		if parsed, err := strconv.Atoi(pacingEnv); err == nil {
+			flashblockPacingMaxMs = parsed
+		}
+	}
+
+	basePacingMinMs, basePacingMaxMs := 4000, 5000
+	if pacingEnv := os.Getenv("BASE_PACING_MIN_MS"); pacingEnv != "" {
+		if parsed, err := strconv.Atoi(pacingEnv); err == nil {
+			basePacingMinMs = parsed
+		}
+	}
+	if pacingEnv := os.Getenv("BASE_PACING_MAX_MS"); pacingEnv != "" {
+		if parsed, err := strconv.Atoi(pacingEnv); err == nil {
+			basePacingMaxMs = parsed
+		}
+	}
+
+	phasePauseMs := 5000
+	if pauseEnv := os.Getenv("PHASE_PAUSE_MS"); pauseEnv != "" {
+		if parsed, err := strconv.Atoi(pauseEnv); err == nil {
+			phasePauseMs = parsed
+		}
+	}
+
+	log.Printf("Pacing: flashblocks %d-%dms, base %d-%dms, phase pause %dms", flashblockPacingMinMs, flashblockPacingMaxMs, basePacingMinMs, basePacingMaxMs, phasePauseMs)
+
 	privateKey, err := crypto.HexToECDSA(key)
 	if err != nil {
 		log.Fatalf("Failed to load private key: %v", err)
@@ -124,8 +474,23 @@ func main() {
 	}
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
-	var flashblockTimings []stats
-	var baseTimings []stats
+	sc, err := loadScenario(fromAddress, toAddress)
+	if err != nil {
+		log.Fatalf("Failed to load scenario: %v", err)
+	}
+	log.Printf("Scenario: %v", sc)
+
+	if simulationMode {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		simulationLanes := append(append(append([]lane{}, flashblockLanes...), gatewayLanes...), baseLanes...)
+		log.Printf("Starting eth_call simulation, lanes=%d, rate=%v/s", len(simulationLanes), simulationRate)
+		if err := runSimulation(ctx, region, simulationLanes, sc, numberOfTransactions, simulationRate, soakMode); err != nil {
+			log.Fatalf("Failed to run simulation: %v", err)
+		}
+		return
+	}
 
 	chainId, err := baseClient.NetworkID(context.Background())
 	log.Printf("Chain ID: %v", chainId)
@@ -133,66 +498,298 @@ func main() {
 		log.Fatalf("Failed to get network ID: %v", err)
 	}
 
+	signer, err := newSigner(os.Getenv("SIGNER_FORK"), chainId)
+	if err != nil {
+		log.Fatalf("Failed to create signer: %v", err)
+	}
+
+	// Pre-signed transactions from external systems, submitted instead of
+	// signing locally
+	var rawTxs []*types.Transaction
+	if rawTxsFile := os.Getenv("RAW_TXS_FILE"); rawTxsFile != "" {
+		rawTxs, err = loadRawTransactions(rawTxsFile)
+		if err != nil {
+			log.Fatalf("Failed to load raw transactions: %v", err)
+		}
+		log.Printf("Loaded %d raw transactions from %s", len(rawTxs), rawTxsFile)
+
+		// Each raw transaction can only be submitted once
+		numberOfTransactions = len(rawTxs)
+		if soakMode {
+			log.Printf("NOTICE: raw transactions can only be submitted once, disabling soak mode")
+			soakMode = false
+		}
+		if retryLimit > 0 {
+			log.Printf("NOTICE: raw transactions can only be submitted once, disabling retries")
+			retryLimit = 0
+		}
+		if runStandardTransactionSending {
+			log.Printf("NOTICE: raw transactions are submitted to the flashblocks endpoint only, skipping regular transactions")
+			runStandardTransactionSending = false
+		}
+	}
+
+	// Catch runs that would only produce reverts or rejections before sending
+	if skipGuardrails {
+		log.Printf("NOTICE: skipping pre-run checks (SKIP_GUARDRAILS=true)")
+	} else {
+		if rawTxs == nil {
+			if err := checkScenario(flashblocksClient, sc); err != nil {
+				log.Fatalf("Scenario check failed: %v", err)
+			}
+		}
+
+		// Raw transactions come from their own senders with their nonces
+		// already set, so only bundles draw on the local key then
+		inFlight := 1
+		if rawTxs != nil {
+			inFlight = 0
+		}
+		if runBundleTest {
+			inFlight = max(inFlight, bundleSize)
+		}
+		if inFlight > 0 {
+			if err := checkPending(flashblocksClient, fromAddress, inFlight, maxPendingTxs); err != nil {
+				log.Fatalf("Pending transaction check failed: %v", err)
+			}
+		}
+	}
+
+	clock, err := newBlockClock(flashblocksClient, time.Duration(blockTimeMs)*time.Millisecond, time.Duration(flashblockIntervalMs)*time.Millisecond)
+	if err != nil {
+		log.Fatalf("Failed to initialise block clock: %v", err)
+	}
+
 	// Bundle testing
 	if runBundleTest {
 		log.Printf("Starting bundle test with %d transactions per bundle", bundleSize)
-		err = createAndSendBundle(chainId, privateKey, fromAddress, toAddress, flashblocksClient, bundleSize)
+		result, err := createAndSendBundle(signer, privateKey, fromAddress, sc, flashblocksClient, bundleSize, pollingIntervalMs)
 		if err != nil {
 			log.Printf("Failed to send bundle: %v", err)
 		} else {
 			log.Printf("Bundle test completed successfully")
 		}
+
+		if err := writeBundlesToFile(fmt.Sprintf("./data/bundles-%s.csv", region), []bundleResult{result}); err != nil {
+			log.Fatalf("Failed to write to file: %v", err)
+		}
 	}
 
-	flashblockErrors := 0
-	baseErrors := 0
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Starting flashblock transactions, syncMode=%v", sendTxnSync)
-	for i := 0; i < numberOfTransactions; i++ {
-		timing, err := timeTransaction(chainId, privateKey, fromAddress, toAddress, flashblocksClient, sendTxnSync, pollingIntervalMs)
+	flashblocksWriter, err := newResultWriter(fmt.Sprintf("./data/flashblocks-%s.csv", region), resultBufferSize, resultFlushInterval, resultCompression, resultRotateRows, resultRotateInterval)
+	if err != nil {
+		log.Fatalf("Failed to open results file: %v", err)
+	}
+
+	var baseWriter *resultWriter
+	if runStandardTransactionSending {
+		baseWriter, err = newResultWriter(fmt.Sprintf("./data/base-%s.csv", region), resultBufferSize, resultFlushInterval, resultCompression, resultRotateRows, resultRotateInterval)
 		if err != nil {
-			flashblockErrors += 1
-			log.Printf("Failed to send transaction: %v", err)
+			log.Fatalf("Failed to open results file: %v", err)
 		}
+	}
 
-		flashblockTimings = append(flashblockTimings, timing)
+	if debugAddr != "" {
+		publishResultWriter("flashblocks", flashblocksWriter)
+		if baseWriter != nil {
+			publishResultWriter("base", baseWriter)
+		}
+	}
+
+	flashblockTimings := newResultWindow(resultWindowSize)
+	baseTimings := newResultWindow(resultWindowSize)
+	latencyHeatmap := newHeatmap()
 
-		if !sendTxnSync {
-			// wait for it to be mined -- sleep a random amount between 600ms and 1s
-			time.Sleep(time.Duration(rand.Int63n(600)+600) * time.Millisecond)
+	var regressions []regression
+
+	flashblockErrors := 0
+	baseErrors := 0
+	flashblockExpired := 0
+	baseExpired := 0
+
+	// Push notifications of inclusion, compared against polling on the same rows
+	var push *inclusionStream
+	if flashblocksWsUrl := os.Getenv("FLASHBLOCKS_WS_URL"); flashblocksWsUrl != "" {
+		topic := os.Getenv("RECEIPT_SUBSCRIPTION")
+		if topic == "" {
+			topic = "newFlashblockTransactions"
+		}
+
+		push, err = startInclusionStream(ctx, flashblocksWsUrl, topic, availability.endpoint("flashblocks-ws"))
+		if err != nil {
+			log.Printf("NOTICE: %s subscription is not available on the flashblocks websocket endpoint, recording poll latency only: %v", topic, err)
+			push = nil
 		} else {
-			time.Sleep(time.Duration(rand.Int63n(200)+200) * time.Millisecond)
+			log.Printf("Subscribed to %s for push inclusion latency", topic)
 		}
 	}
 
-	// wait for the final fb transaction to land
-	time.Sleep(5 * time.Second)
+	// Correlation IDs are sent on submission requests under this header
+	traceHeader := os.Getenv("TRACE_HEADER_NAME")
+	if traceHeader == "" {
+		traceHeader = "X-Request-Id"
+	}
+
+	flashblockLanes = append(flashblockLanes, gatewayLanes...)
+	for i := range flashblockLanes {
+		flashblockLanes[i].sync = sendTxnSync
+		flashblockLanes[i].push = push
+		flashblockLanes[i].traceHeader = traceHeader
+	}
+	for i := range baseLanes {
+		baseLanes[i].traceHeader = traceHeader
+	}
 
+	// Read round trips give the network baseline inclusion latency is compared against
+	pollingInterval := time.Duration(pollingIntervalMs) * time.Millisecond
+	measuredLanes := flashblockLanes
 	if runStandardTransactionSending {
-		log.Printf("Starting regular transactions")
-		for i := 0; i < numberOfTransactions; i++ {
-			// Currently not supported on non-flashblock endpoints
-			timing, err := timeTransaction(chainId, privateKey, fromAddress, toAddress, baseClient, false, pollingIntervalMs)
+		measuredLanes = append(append([]lane{}, flashblockLanes...), baseLanes...)
+	}
+
+	var baselines []networkBaseline
+	for _, ln := range measuredLanes {
+		baseline := networkBaseline{Lane: ln.name, SubmitRTT: measureRoundTrip(ln.submitter, rttSamples), PollingInterval: pollingInterval}
+		baseline.ObserveRTT = baseline.SubmitRTT
+		if ln.observer != ln.submitter {
+			baseline.ObserveRTT = measureRoundTrip(ln.observer, rttSamples)
+		}
+		if ln.sync {
+			// The receipt comes back on the submission request
+			baseline.ObserveRTT = baseline.SubmitRTT
+			baseline.PollingInterval = 0
+		}
+		log.Printf("Lane %s round trip: submit %v, observe %v", ln.name, baseline.SubmitRTT, baseline.ObserveRTT)
+		baselines = append(baselines, baseline)
+	}
+
+	for cycle := 1; ctx.Err() == nil; cycle++ {
+		if soakMode {
+			log.Printf("Starting soak cycle %d", cycle)
+		}
+
+		log.Printf("Starting flashblock transactions, syncMode=%v, lanes=%d", sendTxnSync, len(flashblockLanes))
+		samples := newSamples(numberOfTransactions)
+		retries := 0
+		pending := newBlockQueue(flashblockLanes[0].observer, clock, pollingIntervalMs, func(timing stats) {
+			flashblocksWriter.Write(timing)
+			flashblockTimings.add(timing)
+			latencyHeatmap.add(timing)
+		})
+		for q := 0; q < len(samples) && ctx.Err() == nil; q++ {
+			sm := samples[q]
+			ln := flashblockLanes[sm.index%len(flashblockLanes)]
+			if sm.retry {
+				log.Printf("Retrying sample %d on lane %s", sm.index, ln.name)
+			}
+
+			var timing stats
+			if rawTxs != nil {
+				timing, err = submitTransaction(signer, privateKey, fromAddress, ln.observer, ln, rawTxs[sm.index], pollingIntervalMs, txDeadline)
+			} else {
+				timing, err = timeTransaction(signer, privateKey, fromAddress, sc, ln.observer, ln, pollingIntervalMs, txDeadline)
+			}
+			timing.Phase = phaseFlashblocks
+			timing.Sample = sm.index
+			timing.Retry = sm.retry
+			timing.Lane = ln.name
+			timing.Source = ln.source
+			timing.recordAvailability(ln.availability.snapshot())
 			if err != nil {
-				baseErrors += 1
+				flashblockErrors += 1
+				timing.Status = statusFailed
 				log.Printf("Failed to send transaction: %v", err)
+			} else if timing.Status == statusExpired {
+				flashblockExpired += 1
+			} else {
+				timing.FlashblockIndex = clock.flashblockIndex(timing.IncludedInBlock, wallClock(timing.IncludedAt))
+				if timing.flagClockJump(clockJumpTolerance) {
+					log.Printf("Wall clock jumped while %s was in flight, flagging row", timing.TxnHash)
+				}
+			}
+
+			pending.push(timing)
+
+			if timing.Status != statusIncluded && !sm.retry && retries < retryLimit {
+				samples = append(samples, sample{index: sm.index, retry: true})
+				retries++
 			}
 
-			baseTimings = append(baseTimings, timing)
+			pause(ctx, jitter(flashblockPacingMinMs, flashblockPacingMaxMs))
+		}
 
-			// wait for it to be mined -- sleep a random amount between 4s and 3s
-			time.Sleep(time.Duration(rand.Int63n(1000)+4000) * time.Millisecond)
+		// wait for the final fb transaction to land
+		pause(ctx, time.Duration(phasePauseMs)*time.Millisecond)
+
+		pending.flush()
+
+		if runStandardTransactionSending && ctx.Err() == nil {
+			log.Printf("Starting regular transactions, lanes=%d", len(baseLanes))
+			samples := newSamples(numberOfTransactions)
+			retries := 0
+			pending := newBlockQueue(baseLanes[0].observer, clock, pollingIntervalMs, func(timing stats) {
+				baseWriter.Write(timing)
+				baseTimings.add(timing)
+				latencyHeatmap.add(timing)
+			})
+			for q := 0; q < len(samples) && ctx.Err() == nil; q++ {
+				sm := samples[q]
+				ln := baseLanes[sm.index%len(baseLanes)]
+				if sm.retry {
+					log.Printf("Retrying sample %d on lane %s", sm.index, ln.name)
+				}
+
+				timing, err := timeTransaction(signer, privateKey, fromAddress, sc, ln.observer, ln, pollingIntervalMs, txDeadline)
+				timing.Phase = phaseBase
+				timing.Sample = sm.index
+				timing.Retry = sm.retry
+				timing.Lane = ln.name
+				timing.Source = ln.source
+				timing.recordAvailability(ln.availability.snapshot())
+				if err != nil {
+					baseErrors += 1
+					timing.Status = statusFailed
+					log.Printf("Failed to send transaction: %v", err)
+				} else if timing.Status == statusExpired {
+					baseExpired += 1
+				} else if timing.flagClockJump(clockJumpTolerance) {
+					log.Printf("Wall clock jumped while %s was in flight, flagging row", timing.TxnHash)
+				}
+
+				pending.push(timing)
+
+				if timing.Status != statusIncluded && !sm.retry && retries < retryLimit {
+					samples = append(samples, sample{index: sm.index, retry: true})
+					retries++
+				}
+
+				// wait for it to be mined
+				pause(ctx, jitter(basePacingMinMs, basePacingMaxMs))
+			}
+
+			pending.flush()
+		} else if !runStandardTransactionSending {
+			log.Printf("Skipping regular transactions (RUN_STANDARD_TRANSACTION_SENDING=false)")
+		}
+
+		regressions, err = writeAnalysis(region, flashblockTimings.all(), baseTimings.all(), latencyHeatmap, baselines, availability.snapshots(), efficiencyBaselineLane, baselineSummaries, regressionTolerancePct, regressionToleranceMs)
+		if err != nil {
+			log.Printf("Failed to write analysis: %v", err)
+		}
+
+		if !soakMode {
+			break
 		}
-	} else {
-		log.Printf("Skipping regular transactions (RUN_STANDARD_TRANSACTION_SENDING=false)")
 	}
 
-	if err := writeToFile(fmt.Sprintf("./data/flashblocks-%s.csv", region), flashblockTimings); err != nil {
+	if err := flashblocksWriter.Close(); err != nil {
 		log.Fatalf("Failed to write to file: %v", err)
 	}
 
-	if runStandardTransactionSending {
-		if err := writeToFile(fmt.Sprintf("./data/base-%s.csv", region), baseTimings); err != nil {
+	if baseWriter != nil {
+		if err := baseWriter.Close(); err != nil {
 			log.Fatalf("Failed to write to file: %v", err)
 		}
 	}
@@ -200,45 +797,103 @@ func main() {
 	log.Printf("Completed test with %d transactions", numberOfTransactions)
 	log.Printf("Flashblock errors: %v", flashblockErrors)
 	log.Printf("BaseErrors: %v", baseErrors)
-}
+	log.Printf("Flashblock expired: %v", flashblockExpired)
+	log.Printf("Base expired: %v", baseExpired)
 
-func writeToFile(filename string, data []stats) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Fatalf("Failed to create file: %v", err)
+	if regressed := countRegressed(regressions); regressed > 0 {
+		log.Fatalf("%d percentiles regressed beyond tolerance against baseline %s", regressed, baselineFile)
 	}
-	defer file.Close()
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// writeAnalysis writes the summaries derived from the retained rows. In soak
+// mode it runs after every cycle and overwrites the previous files. With a
+// baseline, summaries are compared against it and the comparisons returned.
+func writeAnalysis(region string, flashblockTimings []stats, baseTimings []stats, latencyHeatmap *heatmap, baselines []networkBaseline, snapshots []availabilitySnapshot, efficiencyBaselineLane string, baselineSummaries []latencySummary, regressionTolerancePct float64, regressionToleranceMs int) ([]regression, error) {
+	allTimings := append(append([]stats{}, flashblockTimings...), baseTimings...)
+	summaries := summarizeRun(flashblockTimings, baseTimings)
+
+	var regressions []regression
+	if baselineSummaries != nil {
+		regressions = compareToBaseline(baselineSummaries, summaries, regressionTolerancePct, regressionToleranceMs)
+		markRegressed(summaries, regressions)
+	}
 
-	header := []string{"sent_at", "txn_hash", "included_in_block", "inclusion_delay_ms"}
-	if err := writer.Write(header); err != nil {
-		log.Fatalf("Failed to write to file: %v", err)
+	logSummaries(summaries)
+	if err := writeSummaries(fmt.Sprintf("./data/summary-%s.csv", region), summaries); err != nil {
+		return regressions, fmt.Errorf("unable to write summary: %v", err)
 	}
 
-	for _, d := range data {
-		row := []string{
-			d.SentAt.String(),
-			d.TxnHash,
-			strconv.FormatUint(d.IncludedInBlock, 10),
-			strconv.FormatInt(d.InclusionDelay.Milliseconds(), 10),
-		}
-		if err := writer.Write(row); err != nil {
-			log.Fatalf("Failed to write to file: %v", err)
+	if baselineSummaries != nil {
+		logRegressions(regressions)
+		if err := writeRegressions(fmt.Sprintf("./data/regression-%s.csv", region), regressions); err != nil {
+			return regressions, fmt.Errorf("unable to write regressions: %v", err)
 		}
 	}
 
-	return nil
+	if err := writeHeatmap(fmt.Sprintf("./data/heatmap-%s.csv", region), latencyHeatmap.cells()); err != nil {
+		return regressions, fmt.Errorf("unable to write heatmap: %v", err)
+	}
+
+	attributions := attribute(region, allTimings, baselines)
+	logAttributions(attributions)
+	if err := writeAttributions(fmt.Sprintf("./data/attribution-%s.csv", region), attributions); err != nil {
+		return regressions, fmt.Errorf("unable to write attribution: %v", err)
+	}
+
+	efficiencies := computeEfficiency(allTimings, efficiencyBaselineLane)
+	logEfficiency(efficiencies)
+	if err := writeEfficiency(fmt.Sprintf("./data/efficiency-%s.csv", region), efficiencies); err != nil {
+		return regressions, fmt.Errorf("unable to write efficiency: %v", err)
+	}
+
+	logAvailability(snapshots)
+	if err := writeAvailability(fmt.Sprintf("./data/availability-%s.csv", region), snapshots); err != nil {
+		return regressions, fmt.Errorf("unable to write availability: %v", err)
+	}
+
+	return regressions, nil
 }
 
-func createTx(chainId *big.Int, privateKey *ecdsa.PrivateKey, toAddress common.Address, client *ethclient.Client, nonce uint64) (*types.Transaction, error) {
+// jitter returns a random duration between minMs and maxMs milliseconds.
+func jitter(minMs int, maxMs int) time.Duration {
+	ms := int64(minMs)
+	if maxMs > minMs {
+		ms += rand.Int63n(int64(maxMs - minMs))
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// pause sleeps for the given duration, returning early on shutdown.
+func pause(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+func createTx(signer types.Signer, privateKey *ecdsa.PrivateKey, sc *scenario, client *ethclient.Client, nonce uint64) (*types.Transaction, error) {
+	payload, err := sc.next()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build payload: %v", err)
+	}
+
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("unable to get gas price: %v", err)
 	}
-	gasLimit := uint64(21000)
-	value := big.NewInt(100)
+
+	gasLimit := payload.Gas
+	if gasLimit == 0 {
+		gasLimit, err = client.EstimateGas(context.Background(), ethereum.CallMsg{
+			From:  sc.from,
+			To:    &payload.To,
+			Value: payload.Value,
+			Data:  payload.Data,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to estimate gas: %v", err)
+		}
+	}
 
 	tip, err := client.SuggestGasTipCap(context.Background())
 	if err != nil {
@@ -246,17 +901,17 @@ func createTx(chainId *big.Int, privateKey *ecdsa.PrivateKey, toAddress common.A
 	}
 
 	tx := types.NewTx(&types.DynamicFeeTx{
-		ChainID:   chainId,
+		ChainID:   signer.ChainID(),
 		Nonce:     nonce,
 		GasTipCap: tip,
 		GasFeeCap: gasPrice,
 		Gas:       gasLimit,
-		To:        &toAddress,
-		Value:     value,
-		Data:      nil,
+		To:        &payload.To,
+		Value:     payload.Value,
+		Data:      payload.Data,
 	})
 
-	signedTx, err := types.SignTx(tx, types.NewPragueSigner(chainId), privateKey)
+	signedTx, err := types.SignTx(tx, signer, privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("unable to sign transaction: %v", err)
 	}
@@ -264,26 +919,75 @@ func createTx(chainId *big.Int, privateKey *ecdsa.PrivateKey, toAddress common.A
 	return signedTx, nil
 }
 
-func timeTransaction(chainId *big.Int, privateKey *ecdsa.PrivateKey, fromAddress common.Address, toAddress common.Address, client *ethclient.Client, useSyncRPC bool, pollingIntervalMs int) (stats, error) {
+func timeTransaction(signer types.Signer, privateKey *ecdsa.PrivateKey, fromAddress common.Address, sc *scenario, client *ethclient.Client, ln lane, pollingIntervalMs int, deadline time.Duration) (stats, error) {
 	// Use pending nonce to avoid conflicts with pending transactions
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
 		return stats{}, fmt.Errorf("unable to get nonce: %v", err)
 	}
 
-	signedTx, err := createTx(chainId, privateKey, toAddress, client, nonce)
+	signedTx, err := createTx(signer, privateKey, sc, client, nonce)
 	if err != nil {
 		return stats{}, fmt.Errorf("unable to create transaction: %v", err)
 	}
 
-	if useSyncRPC {
-		return sendTransactionSync(client, signedTx)
+	return submitTransaction(signer, privateKey, fromAddress, client, ln, signedTx, pollingIntervalMs, deadline)
+}
+
+// submitTransaction sends an already signed transaction through the lane and
+// times its inclusion as observed by client.
+func submitTransaction(signer types.Signer, privateKey *ecdsa.PrivateKey, fromAddress common.Address, client *ethclient.Client, ln lane, signedTx *types.Transaction, pollingIntervalMs int, deadline time.Duration) (stats, error) {
+	if ln.push != nil {
+		ln.push.watch(signedTx.Hash())
 	}
 
-	return sendTransactionAsync(client, signedTx, pollingIntervalMs)
+	traceID := newTraceID()
+	ctx := traceContext(ln.traceHeader, traceID)
+	log.Printf("Sending %s with %s %s", signedTx.Hash().Hex(), ln.traceHeader, traceID)
+
+	var timing stats
+	var err error
+	if ln.sync {
+		timing, err = sendTransactionSync(ctx, ln.submitter, signedTx, deadline)
+	} else {
+		timing, err = sendTransactionAsync(ctx, ln.submitter, client, signedTx, pollingIntervalMs, deadline)
+	}
+	timing.TraceID = traceID
+
+	if ln.push != nil {
+		// Give a notification racing the poll a moment to arrive
+		seenAt, ok := ln.push.take(signedTx.Hash(), time.Duration(pollingIntervalMs)*time.Millisecond)
+		if ok && err == nil && timing.Status == statusIncluded {
+			timing.PushInclusionDelay = seenAt.Sub(timing.SentAt)
+		}
+	}
+
+	if err == nil && signedTx.To() != nil {
+		timing.To = signedTx.To().Hex()
+		timing.Value = signedTx.Value()
+	}
+
+	if err == nil && timing.Status == statusIncluded {
+		timing.tx = signedTx
+	}
+
+	if err == nil && timing.Status == statusExpired {
+		// Only transactions from our own key can be replaced
+		if sender, senderErr := types.Sender(types.LatestSignerForChainID(signedTx.ChainId()), signedTx); senderErr != nil || sender != fromAddress {
+			log.Printf("Transaction %s not included within %v, not sent from %s so it cannot be cancelled", signedTx.Hash().Hex(), deadline, fromAddress.Hex())
+			return timing, nil
+		}
+
+		log.Printf("Transaction %s not included within %v, cancelling", signedTx.Hash().Hex(), deadline)
+		if cancelErr := cancelTransaction(signer, privateKey, fromAddress, client, signedTx); cancelErr != nil {
+			log.Printf("Failed to cancel transaction %s: %v", signedTx.Hash().Hex(), cancelErr)
+		}
+	}
+
+	return timing, err
 }
 
-func sendTransactionSync(client *ethclient.Client, signedTx *types.Transaction) (stats, error) {
+func sendTransactionSync(ctx context.Context, client *ethclient.Client, signedTx *types.Transaction, deadline time.Duration) (stats, error) {
 	rawTx, err := signedTx.MarshalBinary()
 	if err != nil {
 		return stats{}, fmt.Errorf("unable to marshal transaction: %v", err)
@@ -291,9 +995,22 @@ func sendTransactionSync(client *ethclient.Client, signedTx *types.Transaction)
 
 	txnData := "0x" + hex.EncodeToString(rawTx)
 
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
 	sentAt := time.Now()
 	var receipt *types.Receipt
-	err = client.Client().CallContext(context.Background(), &receipt, "eth_sendRawTransactionSync", txnData)
+	err = client.Client().CallContext(ctx, &receipt, "eth_sendRawTransactionSync", txnData)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return stats{
+			SentAt:  sentAt,
+			TxnHash: signedTx.Hash().Hex(),
+			Status:  statusExpired,
+		}, nil
+	}
 	if err != nil {
 		return stats{}, fmt.Errorf("unable to send sync transaction: %v", err)
 	}
@@ -303,109 +1020,155 @@ func sendTransactionSync(client *ethclient.Client, signedTx *types.Transaction)
 	}
 
 	log.Println("Transaction sent sync: ", signedTx.Hash().Hex())
-	now := time.Now()
-	return stats{
+	timing := stats{
 		SentAt:          sentAt,
-		InclusionDelay:  now.Sub(sentAt),
 		TxnHash:         signedTx.Hash().Hex(),
 		IncludedInBlock: receipt.BlockNumber.Uint64(),
-	}, nil
+		Status:          statusIncluded,
+		FeePaid:         receiptFee(receipt),
+	}
+	timing.markIncluded(time.Now())
+	return timing, nil
 }
 
-func sendTransactionAsync(client *ethclient.Client, signedTx *types.Transaction, pollingIntervalMs int) (stats, error) {
+func sendTransactionAsync(ctx context.Context, submitter *ethclient.Client, client *ethclient.Client, signedTx *types.Transaction, pollingIntervalMs int, deadline time.Duration) (stats, error) {
 	sentAt := time.Now()
-	err := client.SendTransaction(context.Background(), signedTx)
+	err := submitter.SendTransaction(ctx, signedTx)
 	if err != nil {
 		return stats{}, fmt.Errorf("unable to send transaction: %v", err)
 	}
 
 	log.Println("Transaction sent async: ", signedTx.Hash().Hex())
 
-	for i := 0; i < 1000; i++ {
+	// With a deadline, poll until it passes so the transaction can be cancelled
+	for i := 0; deadline > 0 || i < 1000; i++ {
+		if deadline > 0 && time.Since(sentAt) > deadline {
+			return stats{
+				SentAt:  sentAt,
+				TxnHash: signedTx.Hash().Hex(),
+				Status:  statusExpired,
+			}, nil
+		}
+
 		receipt, err := client.TransactionReceipt(context.Background(), signedTx.Hash())
 		if err != nil {
 			time.Sleep(time.Duration(pollingIntervalMs) * time.Millisecond)
 		} else {
-			now := time.Now()
-			return stats{
+			timing := stats{
 				SentAt:          sentAt,
-				InclusionDelay:  now.Sub(sentAt),
 				TxnHash:         signedTx.Hash().Hex(),
 				IncludedInBlock: receipt.BlockNumber.Uint64(),
-			}, nil
+				Status:          statusIncluded,
+				FeePaid:         receiptFee(receipt),
+			}
+			timing.markIncluded(time.Now())
+			return timing, nil
 		}
 	}
 
 	return stats{}, fmt.Errorf("failed to get transaction")
 }
 
-func sendBundle(client *ethclient.Client, signedTxs []*types.Transaction, targetBlockNumber uint64) (string, error) {
-	// Convert transactions to raw transaction bytes and collect hashes
-	var txsBytes [][]byte
-	var txHashes []common.Hash
-	for _, tx := range signedTxs {
-		rawTx, err := tx.MarshalBinary()
+// recordBlock fills in the columns that depend on the block the transaction
+// was included in.
+func (s *stats) recordBlock(block *types.Block, tx *types.Transaction) {
+	if block.BaseFee() != nil {
+		s.BaseFee = block.BaseFee()
+		s.EffectiveTip = effectiveTip(tx, block.BaseFee())
+	}
+	if block.GasLimit() > 0 {
+		s.BlockFullness = float64(block.GasUsed()) / float64(block.GasLimit())
+	}
+	s.Builder = blockBuilder(block)
+}
+
+// builderTxPrefix starts the calldata of the transaction external builders
+// such as op-rbuilder add to the blocks they build.
+var builderTxPrefix = []byte("Block Number: ")
+
+// blockBuilder is the sender of the block's builder transaction, empty when
+// the block has none, e.g. when the sequencer built it itself. The coinbase
+// can't be used, on OP Stack chains it is always the sequencer fee vault.
+func blockBuilder(block *types.Block) string {
+	txs := block.Transactions()
+	for i := len(txs) - 1; i >= 0; i-- {
+		if !bytes.HasPrefix(txs[i].Data(), builderTxPrefix) {
+			continue
+		}
+		sender, err := types.Sender(types.LatestSignerForChainID(txs[i].ChainId()), txs[i])
 		if err != nil {
-			return "", fmt.Errorf("unable to marshal transaction: %v", err)
+			return ""
 		}
-		txsBytes = append(txsBytes, rawTx)
-		txHashes = append(txHashes, tx.Hash())
+		return sender.Hex()
 	}
+	return ""
+}
 
-	// Create bundle structure matching Base TIPS format
-	bundle := Bundle{
-		Txs:               txsBytes,
-		BlockNumber:       targetBlockNumber,
-		RevertingTxHashes: txHashes,        // All transaction hashes must be in reverting_tx_hashes
-		DroppingTxHashes:  []common.Hash{}, // Empty array if no dropping txs
+// effectiveTip is the per-gas priority fee the transaction pays on top of the
+// base fee. It is negative when the fee cap is below the base fee.
+func effectiveTip(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	headroom := new(big.Int).Sub(tx.GasFeeCap(), baseFee)
+	if headroom.Cmp(tx.GasTipCap()) < 0 {
+		return headroom
 	}
+	return new(big.Int).Set(tx.GasTipCap())
+}
 
-	// Send bundle via RPC call
-	var bundleHash string
-	err := client.Client().CallContext(context.Background(), &bundleHash, "eth_sendBundle", bundle)
+// cancelTransaction replaces a pending transaction with a zero-value self
+// transfer at the same nonce, so it cannot land late and shift later nonces.
+func cancelTransaction(signer types.Signer, privateKey *ecdsa.PrivateKey, fromAddress common.Address, client *ethclient.Client, pendingTx *types.Transaction) error {
+	tip, err := client.SuggestGasTipCap(context.Background())
 	if err != nil {
-		return "", fmt.Errorf("unable to send bundle: %v", err)
+		return fmt.Errorf("unable to get gas tip cap: %v", err)
 	}
 
-	log.Printf("Bundle sent successfully with hash: %s", bundleHash)
-	return bundleHash, nil
-}
-
-func createAndSendBundle(chainId *big.Int, privateKey *ecdsa.PrivateKey, fromAddress common.Address, toAddress common.Address, client *ethclient.Client, numTxs int) error {
-	// Get current block number for targeting
-	currentBlock, err := client.BlockNumber(context.Background())
+	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		return fmt.Errorf("unable to get current block number: %v", err)
+		return fmt.Errorf("unable to get gas price: %v", err)
 	}
 
-	// Target the next block
-	targetBlock := currentBlock + 1
+	// Replacements must raise both fee fields by at least 10%
+	tip = bigMax(tip, bumpFee(pendingTx.GasTipCap()))
+	feeCap := bigMax(gasPrice, bumpFee(pendingTx.GasFeeCap()))
+	feeCap = bigMax(feeCap, tip)
 
-	// Get base nonce
-	baseNonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   signer.ChainID(),
+		Nonce:     pendingTx.Nonce(),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       21000,
+		To:        &fromAddress,
+		Value:     big.NewInt(0),
+		Data:      nil,
+	})
+
+	signedTx, err := types.SignTx(tx, signer, privateKey)
 	if err != nil {
-		return fmt.Errorf("unable to get nonce: %v", err)
+		return fmt.Errorf("unable to sign transaction: %v", err)
 	}
 
-	// Create multiple signed transactions for the bundle
-	var signedTxs []*types.Transaction
-	for i := 0; i < numTxs; i++ {
-		nonce := baseNonce + uint64(i) // Sequential nonces
-		signedTx, err := createTx(chainId, privateKey, toAddress, client, nonce)
-		if err != nil {
-			return fmt.Errorf("unable to create transaction %d: %v", i, err)
+	if err := client.SendTransaction(context.Background(), signedTx); err != nil {
+		if strings.Contains(err.Error(), "nonce too low") {
+			log.Printf("Transaction %s was included before it could be cancelled", pendingTx.Hash().Hex())
+			return nil
 		}
-
-		signedTxs = append(signedTxs, signedTx)
-		log.Printf("Created transaction %d with nonce %d, hash: %s", i, nonce, signedTx.Hash().Hex())
+		return fmt.Errorf("unable to send cancellation: %v", err)
 	}
 
-	// Send the bundle
-	bundleHash, err := sendBundle(client, signedTxs, targetBlock)
-	if err != nil {
-		return fmt.Errorf("failed to send bundle: %v", err)
-	}
-
-	log.Printf("Bundle sent with hash: %s, targeting block: %d", bundleHash, targetBlock)
+	log.Printf("Cancellation sent: %s replaces %s", signedTx.Hash().Hex(), pendingTx.Hash().Hex())
 	return nil
 }
+
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(11))
+	bumped.Div(bumped, big.NewInt(10))
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+func bigMax(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
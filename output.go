@@ -0,0 +1,326 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var statsHeader = []string{"sent_at", "txn_hash", "included_in_block", "inclusion_delay_ms", "wall_inclusion_delay_ms", "clock_jump", "flashblock_index", "status", "base_fee_wei", "effective_tip_wei", "fee_paid_wei", "lane", "source", "endpoint_failure_streak", "endpoint_unreachable_ms", "to_address", "value_wei", "push_inclusion_delay_ms", "sample", "retry", "block_fullness", "builder", "phase", "trace_id"}
+
+func (d stats) record() []string {
+	return []string{
+		wallClock(d.SentAt).String(),
+		d.TxnHash,
+		strconv.FormatUint(d.IncludedInBlock, 10),
+		strconv.FormatInt(d.InclusionDelay.Milliseconds(), 10),
+		strconv.FormatInt(d.WallInclusionDelay.Milliseconds(), 10),
+		strconv.FormatBool(d.ClockJump),
+		strconv.Itoa(d.FlashblockIndex),
+		d.Status,
+		formatBig(d.BaseFee),
+		formatBig(d.EffectiveTip),
+		formatBig(d.FeePaid),
+		d.Lane,
+		d.Source,
+		strconv.Itoa(d.EndpointFailureStreak),
+		strconv.FormatInt(d.EndpointUnreachable.Milliseconds(), 10),
+		d.To,
+		formatBig(d.Value),
+		formatOptionalMs(d.PushInclusionDelay),
+		strconv.Itoa(d.Sample),
+		strconv.FormatBool(d.Retry),
+		formatFullness(d.BlockFullness),
+		d.Builder,
+		d.Phase,
+		d.TraceID,
+	}
+}
+
+func formatBig(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+func formatFullness(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func formatOptionalMs(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+// resultWriter streams rows to a CSV file as they are produced. Rows are queued
+// in a bounded buffer and written by a background goroutine that flushes to
+// disk periodically, so a crash loses at most one flush interval of results
+// beyond the rows a blockQueue still holds.
+// Write blocks when the buffer is full rather than growing without bound.
+//
+// Output can be compressed with gzip or zstd, and rotated into numbered chunks
+// after a number of rows or an interval so long runs don't need a big disk.
+type resultWriter struct {
+	filename       string
+	compression    string
+	rotateRows     int
+	rotateInterval time.Duration
+	rows           chan stats
+	done           chan struct{}
+	err            error
+
+	chunk   int
+	current *resultChunk
+}
+
+// resultChunk is one output file of a resultWriter.
+type resultChunk struct {
+	file       *os.File
+	compressor compressor
+	writer     *csv.Writer
+	rows       int
+	started    time.Time
+}
+
+// compressor is implemented by both the gzip and zstd writers.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newResultWriter(filename string, bufferSize int, flushInterval time.Duration, compression string, rotateRows int, rotateInterval time.Duration) (*resultWriter, error) {
+	if compression != "" && compression != "gzip" && compression != "zstd" {
+		return nil, fmt.Errorf("unsupported compression %s", compression)
+	}
+
+	w := &resultWriter{
+		filename:       filename,
+		compression:    compression,
+		rotateRows:     rotateRows,
+		rotateInterval: rotateInterval,
+		rows:           make(chan stats, bufferSize),
+		done:           make(chan struct{}),
+	}
+
+	current, err := openResultChunk(w.chunkName(), compression)
+	if err != nil {
+		return nil, err
+	}
+	w.current = current
+
+	go w.run(flushInterval)
+	return w, nil
+}
+
+// chunkName is the file the current chunk is written to. Chunks are only
+// numbered when rotating.
+func (w *resultWriter) chunkName() string {
+	name := strings.TrimSuffix(w.filename, ".csv")
+	if w.rotateRows > 0 || w.rotateInterval > 0 {
+		name = fmt.Sprintf("%s-%04d", name, w.chunk)
+	}
+	name += ".csv"
+
+	switch w.compression {
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	}
+	return name
+}
+
+func openResultChunk(filename string, compression string) (*resultChunk, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file: %v", err)
+	}
+
+	c := &resultChunk{file: file, started: time.Now()}
+	var out io.Writer = file
+	switch compression {
+	case "gzip":
+		c.compressor = gzip.NewWriter(file)
+		out = c.compressor
+	case "zstd":
+		encoder, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unable to create zstd writer: %v", err)
+		}
+		c.compressor = encoder
+		out = encoder
+	}
+
+	c.writer = csv.NewWriter(out)
+	if err := c.writer.Write(statsHeader); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to write header: %v", err)
+	}
+	return c, nil
+}
+
+func (c *resultChunk) flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+	if c.compressor != nil {
+		return c.compressor.Flush()
+	}
+	return nil
+}
+
+func (c *resultChunk) close() error {
+	err := c.flush()
+	if c.compressor != nil && err == nil {
+		err = c.compressor.Close()
+	}
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *resultWriter) shouldRotate() bool {
+	if w.rotateRows > 0 && w.current.rows >= w.rotateRows {
+		return true
+	}
+	return w.rotateInterval > 0 && time.Since(w.current.started) >= w.rotateInterval
+}
+
+// rotate starts the next chunk. When it can't be created rows keep going to
+// the current one.
+func (w *resultWriter) rotate() {
+	w.chunk++
+	next, err := openResultChunk(w.chunkName(), w.compression)
+	if err != nil {
+		w.setErr(err)
+		w.chunk--
+		return
+	}
+
+	w.setErr(w.current.close())
+	w.current = next
+}
+
+func (w *resultWriter) run(flushInterval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case row, ok := <-w.rows:
+			if !ok {
+				w.setErr(w.current.close())
+				return
+			}
+			if w.shouldRotate() {
+				w.rotate()
+			}
+			w.setErr(w.current.writer.Write(row.record()))
+			w.current.rows++
+		case <-ticker.C:
+			w.setErr(w.current.flush())
+		}
+	}
+}
+
+func (w *resultWriter) setErr(err error) {
+	if err != nil && w.err == nil {
+		log.Printf("Failed to write to %s: %v", w.filename, err)
+		w.err = err
+	}
+}
+
+func (w *resultWriter) Write(row stats) {
+	w.rows <- row
+}
+
+// Close writes any buffered rows and closes the file.
+func (w *resultWriter) Close() error {
+	close(w.rows)
+	<-w.done
+	return w.err
+}
+
+// openResults opens a results file for reading, decompressing it when it was
+// written with compression.
+func openResults(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %v", err)
+	}
+
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unable to read gzip: %v", err)
+		}
+		return &decompressedFile{Reader: reader, file: file, close: func() { reader.Close() }}, nil
+	case strings.HasSuffix(filename, ".zst"):
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unable to read zstd: %v", err)
+		}
+		return &decompressedFile{Reader: decoder, file: file, close: decoder.Close}, nil
+	}
+	return file, nil
+}
+
+type decompressedFile struct {
+	io.Reader
+	file  *os.File
+	close func()
+}
+
+func (d *decompressedFile) Close() error {
+	d.close()
+	return d.file.Close()
+}
+
+// resultWindow keeps the rows analysis is computed from. With a limit it only
+// retains the most recent rows, which bounds memory in soak mode.
+type resultWindow struct {
+	rows  []stats
+	limit int
+	next  int
+}
+
+func newResultWindow(limit int) *resultWindow {
+	return &resultWindow{limit: limit}
+}
+
+func (w *resultWindow) add(row stats) {
+	if w.limit <= 0 || len(w.rows) < w.limit {
+		w.rows = append(w.rows, row)
+		return
+	}
+	w.rows[w.next] = row
+	w.next = (w.next + 1) % w.limit
+}
+
+// all returns the retained rows, oldest first.
+func (w *resultWindow) all() []stats {
+	return append(append([]stats{}, w.rows[w.next:]...), w.rows[:w.next]...)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// regression compares one percentile of a summary group with the baseline.
+type regression struct {
+	Dimension  string
+	Group      string
+	Percentile string
+	Baseline   time.Duration
+	Current    time.Duration
+	ChangePct  float64
+	Regressed  bool
+}
+
+// loadBaseline reads the summaries a run is compared against from a comma
+// separated list of files. Each is either a summary file or a results file,
+// whose rows are summarized the same way as the current run's.
+func loadBaseline(filenames string) ([]latencySummary, error) {
+	var summaries []latencySummary
+	var flashblockTimings, baseTimings []stats
+	for _, filename := range strings.Split(filenames, ",") {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+
+		fileSummaries, rows, err := readBaselineFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", filename, err)
+		}
+		summaries = append(summaries, fileSummaries...)
+		for _, row := range rows {
+			if row.Phase == phaseBase {
+				baseTimings = append(baseTimings, row)
+			} else {
+				flashblockTimings = append(flashblockTimings, row)
+			}
+		}
+	}
+
+	if flashblockTimings != nil || baseTimings != nil {
+		summaries = append(summaries, summarizeRun(flashblockTimings, baseTimings)...)
+	}
+	return summaries, nil
+}
+
+func readBaselineFile(filename string) ([]latencySummary, []stats, error) {
+	file, err := openResults(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	_, isSummary := columns["p50_ms"]
+	_, isResults := columns["txn_hash"]
+	if !isSummary && !isResults {
+		return nil, nil, fmt.Errorf("neither a summary nor a results file")
+	}
+
+	var summaries []latencySummary
+	var rows []stats
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read row: %v", err)
+		}
+
+		if isSummary {
+			summaries = append(summaries, parseSummary(record, columns))
+			continue
+		}
+
+		row, err := parseRecord(record, columns)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse row: %v", err)
+		}
+		if row.Phase == "" {
+			row.Phase = phaseFromFilename(filename)
+		}
+		if row.Lane == "" {
+			row.Lane = row.Phase
+		}
+		rows = append(rows, row)
+	}
+
+	return summaries, rows, nil
+}
+
+func parseSummary(record []string, columns map[string]int) latencySummary {
+	field := func(name string) string {
+		return recordField(record, columns, name)
+	}
+
+	count, _ := strconv.Atoi(field("count"))
+	return latencySummary{
+		Dimension: field("dimension"),
+		Group:     field("group"),
+		Count:     count,
+		P50:       parseMs(field("p50_ms")),
+		P90:       parseMs(field("p90_ms")),
+		P99:       parseMs(field("p99_ms")),
+	}
+}
+
+// compareToBaseline compares p50, p90 and p99 of every group present in both
+// the baseline and the current run. A percentile regressed when it grew by
+// more than tolerancePct percent and more than toleranceMs milliseconds, the
+// latter keeping jitter on very fast groups from being flagged.
+func compareToBaseline(baseline []latencySummary, current []latencySummary, tolerancePct float64, toleranceMs int) []regression {
+	baselineByGroup := make(map[string]latencySummary)
+	for _, s := range baseline {
+		baselineByGroup[s.Dimension+"/"+s.Group] = s
+	}
+
+	var regressions []regression
+	for _, c := range current {
+		b, ok := baselineByGroup[c.Dimension+"/"+c.Group]
+		if !ok || b.Count == 0 || c.Count == 0 {
+			continue
+		}
+
+		percentiles := []struct {
+			name     string
+			baseline time.Duration
+			current  time.Duration
+		}{
+			{"p50", b.P50, c.P50},
+			{"p90", b.P90, c.P90},
+			{"p99", b.P99, c.P99},
+		}
+		for _, p := range percentiles {
+			r := regression{
+				Dimension:  c.Dimension,
+				Group:      c.Group,
+				Percentile: p.name,
+				Baseline:   p.baseline,
+				Current:    p.current,
+			}
+			if p.baseline > 0 {
+				r.ChangePct = 100 * float64(p.current-p.baseline) / float64(p.baseline)
+			}
+			delta := p.current - p.baseline
+			r.Regressed = r.ChangePct > tolerancePct && delta > time.Duration(toleranceMs)*time.Millisecond
+			regressions = append(regressions, r)
+		}
+	}
+	return regressions
+}
+
+// markRegressed flags the summaries with a percentile that regressed, so the
+// summary file shows them alongside the latency.
+func markRegressed(summaries []latencySummary, regressions []regression) {
+	for i, s := range summaries {
+		for _, r := range regressions {
+			if r.Regressed && r.Dimension == s.Dimension && r.Group == s.Group {
+				summaries[i].Regressed = true
+			}
+		}
+	}
+}
+
+// countRegressed returns how many comparisons regressed beyond tolerance.
+func countRegressed(regressions []regression) int {
+	regressed := 0
+	for _, r := range regressions {
+		if r.Regressed {
+			regressed++
+		}
+	}
+	return regressed
+}
+
+func logRegressions(regressions []regression) {
+	for _, r := range regressions {
+		if r.Regressed {
+			log.Printf("REGRESSION %s=%s %s baseline=%dms current=%dms change=%+.1f%%", r.Dimension, r.Group, r.Percentile, r.Baseline.Milliseconds(), r.Current.Milliseconds(), r.ChangePct)
+		}
+	}
+	log.Printf("Compared %d percentiles against the baseline, %d regressed", len(regressions), countRegressed(regressions))
+}
+
+func writeRegressions(filename string, regressions []regression) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"dimension", "group", "percentile", "baseline_ms", "current_ms", "change_pct", "regressed"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, r := range regressions {
+		row := []string{
+			r.Dimension,
+			r.Group,
+			r.Percentile,
+			strconv.FormatInt(r.Baseline.Milliseconds(), 10),
+			strconv.FormatInt(r.Current.Milliseconds(), 10),
+			strconv.FormatFloat(r.ChangePct, 'f', 1, 64),
+			strconv.FormatBool(r.Regressed),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// sentAtLayout is how time.Time.String formats sent_at. Files written before
+// monotonic readings were dropped carry an extra " m=+..." suffix.
+const sentAtLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// replayResults re-times the rows of a previous results file against an
+// archive endpoint. Receipts and blocks are fetched again to recompute the
+// block relative columns, and the rows are written in the current schema.
+// Columns the old file didn't have and that can't be recomputed stay empty.
+func replayResults(client *ethclient.Client, blockTime time.Duration, flashblockInterval time.Duration, inputFile string, outputFile string) (int, error) {
+	file, err := openResults(inputFile)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("unable to read header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	if _, ok := columns["txn_hash"]; !ok {
+		return 0, fmt.Errorf("%s has no txn_hash column", inputFile)
+	}
+
+	defaultPhase := phaseFromFilename(inputFile)
+
+	writer, err := newResultWriter(outputFile, 1000, 5*time.Second, "", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writer.Close()
+			return replayed, fmt.Errorf("unable to read row: %v", err)
+		}
+
+		row, err := parseRecord(record, columns)
+		if err != nil {
+			writer.Close()
+			return replayed, fmt.Errorf("unable to parse row %d: %v", replayed+1, err)
+		}
+		if row.Phase == "" {
+			row.Phase = defaultPhase
+		}
+		if row.Lane == "" {
+			row.Lane = row.Phase
+		}
+
+		if row.Status == statusIncluded {
+			if err := replayRow(client, blockTime, flashblockInterval, &row); err != nil {
+				log.Printf("Failed to replay %s, keeping recorded values: %v", row.TxnHash, err)
+			}
+		}
+
+		writer.Write(row)
+		replayed++
+	}
+
+	return replayed, writer.Close()
+}
+
+// replayRow refreshes an included row from its receipt, transaction and block.
+func replayRow(client *ethclient.Client, blockTime time.Duration, flashblockInterval time.Duration, row *stats) error {
+	hash := common.HexToHash(row.TxnHash)
+	receipt, err := client.TransactionReceipt(context.Background(), hash)
+	if err != nil {
+		return fmt.Errorf("unable to get receipt: %v", err)
+	}
+
+	tx, _, err := client.TransactionByHash(context.Background(), hash)
+	if err != nil {
+		return fmt.Errorf("unable to get transaction: %v", err)
+	}
+
+	block, err := client.BlockByNumber(context.Background(), receipt.BlockNumber)
+	if err != nil {
+		return fmt.Errorf("unable to get block: %v", err)
+	}
+
+	row.IncludedInBlock = receipt.BlockNumber.Uint64()
+	row.FeePaid = receiptFee(receipt)
+	row.recordBlock(block, tx)
+	if tx.To() != nil {
+		row.To = tx.To().Hex()
+		row.Value = tx.Value()
+	}
+
+	if row.Phase == phaseFlashblocks {
+		// Anchor on the block itself, which may predate any block time change
+		clock := &blockClock{
+			anchorNumber:       row.IncludedInBlock,
+			anchorTime:         time.Unix(int64(block.Time()), 0),
+			blockTime:          blockTime,
+			flashblockInterval: flashblockInterval,
+		}
+		row.FlashblockIndex = clock.flashblockIndex(row.IncludedInBlock, row.SentAt.Add(row.WallInclusionDelay))
+	}
+
+	return nil
+}
+
+// phaseFromFilename is the phase of rows in results files from before the
+// phase column existed, which only held one phase each.
+func phaseFromFilename(filename string) string {
+	if strings.HasPrefix(filepath.Base(filename), "base-") {
+		return phaseBase
+	}
+	return phaseFlashblocks
+}
+
+// parseRecord reads the columns a results file has into a row.
+func parseRecord(record []string, columns map[string]int) (stats, error) {
+	field := func(name string) string {
+		return recordField(record, columns, name)
+	}
+
+	var row stats
+	var err error
+
+	sentAt := field("sent_at")
+	if i := strings.Index(sentAt, " m="); i >= 0 {
+		sentAt = sentAt[:i]
+	}
+	if row.SentAt, err = time.Parse(sentAtLayout, sentAt); err != nil {
+		return row, fmt.Errorf("unable to parse sent_at: %v", err)
+	}
+
+	row.TxnHash = field("txn_hash")
+	row.IncludedInBlock, _ = strconv.ParseUint(field("included_in_block"), 10, 64)
+	row.InclusionDelay = parseMs(field("inclusion_delay_ms"))
+	row.WallInclusionDelay = parseMs(field("wall_inclusion_delay_ms"))
+	if field("wall_inclusion_delay_ms") == "" {
+		row.WallInclusionDelay = row.InclusionDelay
+	}
+	row.ClockJump = field("clock_jump") == "true"
+	row.FlashblockIndex, _ = strconv.Atoi(field("flashblock_index"))
+
+	// Files from before statuses were recorded hold included rows and failed
+	// sends, which were written with an empty hash and a zero sent_at
+	row.Status = field("status")
+	if row.Status == "" {
+		row.Status = statusIncluded
+		if row.TxnHash == "" || row.SentAt.IsZero() || row.IncludedInBlock == 0 {
+			row.Status = statusFailed
+		}
+	}
+
+	row.BaseFee, _ = new(big.Int).SetString(field("base_fee_wei"), 10)
+	row.EffectiveTip, _ = new(big.Int).SetString(field("effective_tip_wei"), 10)
+	row.FeePaid, _ = new(big.Int).SetString(field("fee_paid_wei"), 10)
+	row.BlockFullness, _ = strconv.ParseFloat(field("block_fullness"), 64)
+	row.Builder = field("builder")
+	row.Lane = field("lane")
+	row.Source = field("source")
+	row.EndpointFailureStreak, _ = strconv.Atoi(field("endpoint_failure_streak"))
+	row.EndpointUnreachable = parseMs(field("endpoint_unreachable_ms"))
+	row.To = field("to_address")
+	row.Value, _ = new(big.Int).SetString(field("value_wei"), 10)
+	row.PushInclusionDelay = parseMs(field("push_inclusion_delay_ms"))
+	row.Phase = field("phase")
+	row.TraceID = field("trace_id")
+	row.Sample, _ = strconv.Atoi(field("sample"))
+	row.Retry = field("retry") == "true"
+	row.IncludedAt = row.SentAt.Add(row.InclusionDelay)
+
+	return row, nil
+}
+
+// recordField returns the named column of a CSV record, empty when the file
+// doesn't have it.
+func recordField(record []string, columns map[string]int, name string) string {
+	if i, ok := columns[name]; ok && i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+func parseMs(value string) time.Duration {
+	ms, _ := strconv.ParseInt(value, 10, 64)
+	return time.Duration(ms) * time.Millisecond
+}
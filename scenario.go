@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// txPayload is everything about a transaction except nonce and fees.
+type txPayload struct {
+	To    common.Address
+	Value *big.Int
+	Gas   uint64 // zero means estimate
+	Data  []byte
+}
+
+// scenario describes what each test transaction does. By default it is a plain
+// transfer to TO_ADDRESS; with CONTRACT_ADDRESS set it calls a contract method
+// whose arguments may contain template variables expanded per transaction:
+//
+//	{{index}}     sequence number of the transaction within the run
+//	{{salt}}      32 random bytes, hex encoded
+//	{{timestamp}} current unix time in seconds
+//	{{from}}      sender address
+//	{{recipient}} recipient chosen for this transaction
+//	{{value}}     value in wei sent with this transaction
+//	{{memo}}      sequence number as 8 bytes, hex encoded
+//
+// Transactions are further varied so they aren't byte-identical apart from the
+// nonce: the value can be drawn from a range, the recipient from a list, and
+// plain transfers can carry the memo as calldata.
+type scenario struct {
+	from       common.Address
+	to         common.Address
+	value      *big.Int
+	valueMax   *big.Int // nil means value is fixed
+	recipients []common.Address
+	memo       bool
+	gasLimit   uint64
+	method     *abi.Method
+	args       []string
+	index      uint64
+}
+
+func newTransferScenario(fromAddress common.Address, toAddress common.Address) *scenario {
+	return &scenario{
+		from:     fromAddress,
+		to:       toAddress,
+		value:    big.NewInt(100),
+		gasLimit: 21000,
+	}
+}
+
+// loadScenario reads the scenario configuration from the environment.
+func loadScenario(fromAddress common.Address, toAddress common.Address) (*scenario, error) {
+	sc, err := loadContractScenario(fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	if sc == nil {
+		sc = newTransferScenario(fromAddress, toAddress)
+	}
+
+	sc.recipients = []common.Address{toAddress}
+	if recipientsEnv := os.Getenv("TO_ADDRESSES"); recipientsEnv != "" {
+		sc.recipients = nil
+		for _, raw := range strings.Split(recipientsEnv, ",") {
+			raw = strings.TrimSpace(raw)
+			if !common.IsHexAddress(raw) {
+				return nil, fmt.Errorf("TO_ADDRESSES contains an invalid address: %s", raw)
+			}
+			sc.recipients = append(sc.recipients, common.HexToAddress(raw))
+		}
+	}
+
+	if minEnv := os.Getenv("VALUE_MIN_WEI"); minEnv != "" {
+		if _, ok := sc.value.SetString(minEnv, 10); !ok {
+			return nil, fmt.Errorf("VALUE_MIN_WEI is not a valid integer: %s", minEnv)
+		}
+	}
+	if maxEnv := os.Getenv("VALUE_MAX_WEI"); maxEnv != "" {
+		valueMax, ok := new(big.Int).SetString(maxEnv, 10)
+		if !ok {
+			return nil, fmt.Errorf("VALUE_MAX_WEI is not a valid integer: %s", maxEnv)
+		}
+		if valueMax.Cmp(sc.value) < 0 {
+			return nil, fmt.Errorf("VALUE_MAX_WEI %s is below the minimum value %s", valueMax, sc.value)
+		}
+		sc.valueMax = valueMax
+	}
+
+	// Contract calls carry the memo through the {{memo}} template instead
+	sc.memo = os.Getenv("MEMO_CALLDATA") == "true" && sc.method == nil
+
+	return sc, nil
+}
+
+// loadContractScenario reads the contract call configuration, returning nil
+// when CONTRACT_ADDRESS is not set.
+func loadContractScenario(fromAddress common.Address) (*scenario, error) {
+	contractAddressRaw := os.Getenv("CONTRACT_ADDRESS")
+	if contractAddressRaw == "" {
+		return nil, nil
+	}
+
+	if !common.IsHexAddress(contractAddressRaw) {
+		return nil, fmt.Errorf("CONTRACT_ADDRESS is not a valid address: %s", contractAddressRaw)
+	}
+
+	abiRaw := strings.TrimSpace(os.Getenv("CONTRACT_ABI"))
+	if abiRaw == "" {
+		return nil, fmt.Errorf("CONTRACT_ABI environment variable not set")
+	}
+	// Accept a single fragment as well as a full ABI array
+	if strings.HasPrefix(abiRaw, "{") {
+		abiRaw = "[" + abiRaw + "]"
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(abiRaw))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CONTRACT_ABI: %v", err)
+	}
+
+	methodName := os.Getenv("CONTRACT_METHOD")
+	method, ok := parsed.Methods[methodName]
+	if !ok {
+		return nil, fmt.Errorf("method %q not found in CONTRACT_ABI", methodName)
+	}
+
+	var args []string
+	if argsRaw := os.Getenv("CONTRACT_ARGS"); argsRaw != "" {
+		args, err = parseArgs(argsRaw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse CONTRACT_ARGS: %v", err)
+		}
+	}
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("method %s takes %d arguments, CONTRACT_ARGS has %d", method.Sig, len(method.Inputs), len(args))
+	}
+
+	value := big.NewInt(0)
+	if valueEnv := os.Getenv("CONTRACT_VALUE_WEI"); valueEnv != "" {
+		if _, ok := value.SetString(valueEnv, 10); !ok {
+			return nil, fmt.Errorf("CONTRACT_VALUE_WEI is not a valid integer: %s", valueEnv)
+		}
+	}
+
+	var gasLimit uint64
+	if gasEnv := os.Getenv("CONTRACT_GAS_LIMIT"); gasEnv != "" {
+		gasLimit, err = strconv.ParseUint(gasEnv, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("CONTRACT_GAS_LIMIT is not a valid integer: %s", gasEnv)
+		}
+	}
+
+	return &scenario{
+		from:     fromAddress,
+		to:       common.HexToAddress(contractAddressRaw),
+		value:    value,
+		gasLimit: gasLimit,
+		method:   &method,
+		args:     args,
+	}, nil
+}
+
+// parseArgs decodes a JSON array of arguments. Non-string values are kept in
+// their JSON text form so large integers don't lose precision.
+func parseArgs(raw string) ([]string, error) {
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, err
+	}
+
+	args := make([]string, len(values))
+	for i, v := range values {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			args[i] = s
+		} else {
+			args[i] = string(v)
+		}
+	}
+	return args, nil
+}
+
+func (s *scenario) String() string {
+	value := s.value.String()
+	if s.valueMax != nil {
+		value = fmt.Sprintf("%s-%s", s.value, s.valueMax)
+	}
+
+	if s.method == nil {
+		return fmt.Sprintf("transfer of %s wei to %d recipient(s), memo=%v", value, len(s.recipients), s.memo)
+	}
+	return fmt.Sprintf("call %s on %s with %s wei", s.method.Sig, s.to.Hex(), value)
+}
+
+// next returns the payload for the next transaction, expanding templates.
+func (s *scenario) next() (txPayload, error) {
+	index := s.index
+	s.index++
+
+	recipient, err := s.nextRecipient()
+	if err != nil {
+		return txPayload{}, err
+	}
+
+	value, err := s.nextValue()
+	if err != nil {
+		return txPayload{}, err
+	}
+
+	payload := txPayload{
+		To:    s.to,
+		Value: value,
+		Gas:   s.gasLimit,
+	}
+
+	if s.method == nil {
+		payload.To = recipient
+		if s.memo {
+			payload.Data = memo(index)
+			payload.Gas = transferGas(payload.Data)
+		}
+		return payload, nil
+	}
+
+	values := make([]interface{}, len(s.args))
+	for i, arg := range s.args {
+		expanded, err := s.expand(arg, index, recipient, value)
+		if err != nil {
+			return txPayload{}, err
+		}
+
+		values[i], err = convertArg(s.method.Inputs[i].Type, expanded)
+		if err != nil {
+			return txPayload{}, fmt.Errorf("argument %d (%s): %v", i, s.method.Inputs[i].Name, err)
+		}
+	}
+
+	packed, err := s.method.Inputs.Pack(values...)
+	if err != nil {
+		return txPayload{}, fmt.Errorf("unable to encode calldata: %v", err)
+	}
+
+	payload.Data = append(append([]byte{}, s.method.ID...), packed...)
+	return payload, nil
+}
+
+func (s *scenario) nextRecipient() (common.Address, error) {
+	if len(s.recipients) == 1 {
+		return s.recipients[0], nil
+	}
+
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(s.recipients))))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("unable to pick recipient: %v", err)
+	}
+	return s.recipients[i.Int64()], nil
+}
+
+// nextValue draws uniformly from [value, valueMax].
+func (s *scenario) nextValue() (*big.Int, error) {
+	if s.valueMax == nil {
+		return new(big.Int).Set(s.value), nil
+	}
+
+	span := new(big.Int).Sub(s.valueMax, s.value)
+	offset, err := rand.Int(rand.Reader, span.Add(span, big.NewInt(1)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to pick value: %v", err)
+	}
+	return offset.Add(offset, s.value), nil
+}
+
+func memo(index uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, index)
+}
+
+// transferGas is the intrinsic gas of a plain transfer carrying data, taking
+// the calldata floor price into account.
+func transferGas(data []byte) uint64 {
+	var zero, nonZero uint64
+	for _, b := range data {
+		if b == 0 {
+			zero++
+		} else {
+			nonZero++
+		}
+	}
+
+	standard := 21000 + zero*4 + nonZero*16
+	floor := 21000 + (zero+nonZero*4)*10
+	return max(standard, floor)
+}
+
+func (s *scenario) expand(arg string, index uint64, recipient common.Address, value *big.Int) (string, error) {
+	if !strings.Contains(arg, "{{") {
+		return arg, nil
+	}
+
+	if strings.Contains(arg, "{{salt}}") {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("unable to generate salt: %v", err)
+		}
+		arg = strings.ReplaceAll(arg, "{{salt}}", hexutil.Encode(salt))
+	}
+
+	replacer := strings.NewReplacer(
+		"{{index}}", strconv.FormatUint(index, 10),
+		"{{timestamp}}", strconv.FormatInt(time.Now().Unix(), 10),
+		"{{from}}", s.from.Hex(),
+		"{{recipient}}", recipient.Hex(),
+		"{{value}}", value.String(),
+		"{{memo}}", hexutil.Encode(memo(index)),
+	)
+	return replacer.Replace(arg), nil
+}
+
+// convertArg turns a textual argument into the Go value the ABI encoder
+// expects for the given type.
+func convertArg(t abi.Type, raw string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(raw) {
+			return nil, fmt.Errorf("invalid address %q", raw)
+		}
+		return common.HexToAddress(raw), nil
+
+	case abi.BoolTy:
+		return strconv.ParseBool(raw)
+
+	case abi.StringTy:
+		return raw, nil
+
+	case abi.BytesTy:
+		return hexutil.Decode(raw)
+
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.Size {
+			return nil, fmt.Errorf("%d bytes do not fit in bytes%d", len(b), t.Size)
+		}
+		array := reflect.New(t.GetType()).Elem()
+		reflect.Copy(array, reflect.ValueOf(b))
+		return array.Interface(), nil
+
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(raw, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", raw)
+		}
+
+		// Out of range values would otherwise be truncated into other calldata
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+		minimum := new(big.Int)
+		if t.T == abi.IntTy {
+			limit.Rsh(limit, 1)
+			minimum.Neg(limit)
+		}
+		if n.Cmp(minimum) < 0 || n.Cmp(limit) >= 0 {
+			return nil, fmt.Errorf("%s does not fit in %s", raw, t.String())
+		}
+
+		goType := t.GetType()
+		if goType.Kind() == reflect.Ptr {
+			return n, nil
+		}
+		if t.T == abi.UintTy {
+			return reflect.ValueOf(n.Uint64()).Convert(goType).Interface(), nil
+		}
+		return reflect.ValueOf(n.Int64()).Convert(goType).Interface(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported argument type %s", t.String())
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newSigner returns the signer for the named fork, defaulting to Prague.
+func newSigner(fork string, chainId *big.Int) (types.Signer, error) {
+	switch strings.ToLower(fork) {
+	case "", "prague":
+		return types.NewPragueSigner(chainId), nil
+	case "isthmus":
+		return types.NewIsthmusSigner(chainId), nil
+	case "cancun":
+		return types.NewCancunSigner(chainId), nil
+	case "london":
+		return types.NewLondonSigner(chainId), nil
+	case "eip155":
+		return types.NewEIP155Signer(chainId), nil
+	case "latest":
+		return types.LatestSignerForChainID(chainId), nil
+	default:
+		return nil, fmt.Errorf("unknown signer fork %q", fork)
+	}
+}
+
+// loadRawTransactions reads pre-signed transactions, one hex encoded blob per
+// line. Blank lines and lines starting with # are ignored.
+func loadRawTransactions(filename string) ([]*types.Transaction, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %v", err)
+	}
+	defer file.Close()
+
+	var txs []*types.Transaction
+	scanner := bufio.NewScanner(file)
+	// Blobs for large calldata can exceed the default line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "0x") {
+			line = "0x" + line
+		}
+
+		rawTx, err := hexutil.Decode(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unable to decode hex: %v", lineNumber, err)
+		}
+
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(rawTx); err != nil {
+			return nil, fmt.Errorf("line %d: unable to decode transaction: %v", lineNumber, err)
+		}
+		txs = append(txs, tx)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read file: %v", err)
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("no transactions found in %s", filename)
+	}
+
+	return txs, nil
+}
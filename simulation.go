@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	simulationOk       = "ok"
+	simulationReverted = "reverted"
+	simulationFailed   = "failed"
+)
+
+type simulationResult struct {
+	SentAt  time.Time
+	Lane    string
+	Source  string
+	Latency time.Duration
+	Status  string
+	Error   string
+}
+
+// simulateCall times an eth_call of the payload, which executes it without
+// changing state or spending gas. A revert is a successful round trip.
+func simulateCall(client *ethclient.Client, from common.Address, payload txPayload) simulationResult {
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    &payload.To,
+		Gas:   payload.Gas,
+		Value: payload.Value,
+		Data:  payload.Data,
+	}
+
+	sentAt := time.Now()
+	_, err := client.CallContract(context.Background(), msg, nil)
+	result := simulationResult{SentAt: sentAt, Latency: time.Since(sentAt), Status: simulationOk}
+
+	var rpcErr rpc.Error
+	switch {
+	case err == nil:
+	case errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3, strings.Contains(err.Error(), "execution reverted"):
+		result.Status = simulationReverted
+		result.Error = err.Error()
+	default:
+		result.Status = simulationFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runSimulation calls the scenario payload against every lane once per tick,
+// at rate ticks per second, streaming each call to simulation-<region>.csv.
+// Per-lane latency is summarized after every cycle; in soak mode cycles repeat
+// until shutdown.
+func runSimulation(ctx context.Context, region string, lanes []lane, sc *scenario, ticks int, rate float64, soakMode bool) error {
+	file, err := os.Create(fmt.Sprintf("./data/simulation-%s.csv", region))
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"sent_at", "lane", "source", "latency_ms", "status", "error"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	for cycle := 1; ctx.Err() == nil; cycle++ {
+		if soakMode {
+			log.Printf("Starting simulation cycle %d", cycle)
+		}
+
+		latencies := make(map[string][]time.Duration)
+		failures := make(map[string]int)
+		for i := 0; i < ticks && ctx.Err() == nil; i++ {
+			tickStart := time.Now()
+			for _, ln := range lanes {
+				payload, err := sc.next()
+				if err != nil {
+					return fmt.Errorf("unable to build payload: %v", err)
+				}
+
+				result := simulateCall(ln.submitter, sc.from, payload)
+				result.Lane = ln.name
+				result.Source = ln.source
+				if result.Status == simulationFailed {
+					failures[ln.name]++
+				} else {
+					latencies[ln.name] = append(latencies[ln.name], result.Latency)
+				}
+
+				row := []string{
+					wallClock(result.SentAt).String(),
+					result.Lane,
+					result.Source,
+					strconv.FormatInt(result.Latency.Milliseconds(), 10),
+					result.Status,
+					result.Error,
+				}
+				if err := writer.Write(row); err != nil {
+					return fmt.Errorf("unable to write row: %v", err)
+				}
+			}
+			pause(ctx, interval-time.Since(tickStart))
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("unable to write rows: %v", err)
+		}
+
+		var summaries []latencySummary
+		for _, ln := range lanes {
+			summaries = append(summaries, summarize("simulation", ln.name, latencies[ln.name]))
+			if failures[ln.name] > 0 {
+				log.Printf("lane=%s simulation failures=%d", ln.name, failures[ln.name])
+			}
+		}
+		logSummaries(summaries)
+		if err := writeSummaries(fmt.Sprintf("./data/simulation-summary-%s.csv", region), summaries); err != nil {
+			return fmt.Errorf("unable to write simulation summary: %v", err)
+		}
+
+		if !soakMode {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// parseSources splits a comma separated list of local source IPs or interface
+// names. An empty list yields a single empty source, which leaves the choice
+// of local address to the operating system.
+func parseSources(list string) []string {
+	var sources []string
+	for _, source := range strings.Split(list, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return []string{""}
+	}
+	return sources
+}
+
+// sourceAddr resolves a source to the local address to bind to. A source that
+// isn't an IP is looked up as an interface name, preferring its IPv4 address.
+func sourceAddr(source string) (net.IP, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find interface %s: %v", source, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get addresses of interface %s: %v", source, err)
+	}
+
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipNet.IP
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no usable address", source)
+	}
+	return fallback, nil
+}
+
+// laneName labels a lane with the source it sends from, so each vantage point
+// is summarized separately.
+func laneName(name string, source string) string {
+	if source == "" {
+		return name
+	}
+	return name + "@" + source
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// inclusionStream listens for push notifications of included transactions on
+// a websocket subscription. Only hashes registered with watch are tracked, so
+// memory stays bounded regardless of how busy the chain is.
+type inclusionStream struct {
+	url          string
+	topic        string
+	availability *endpointAvailability
+
+	mu      sync.Mutex
+	watched map[common.Hash]time.Time
+}
+
+// startInclusionStream subscribes to topic on the websocket endpoint and keeps
+// the subscription alive, reconnecting until ctx is cancelled. An error is
+// only returned when the first subscription attempt fails.
+func startInclusionStream(ctx context.Context, url string, topic string, availability *endpointAvailability) (*inclusionStream, error) {
+	s := &inclusionStream{
+		url:          url,
+		topic:        topic,
+		availability: availability,
+		watched:      make(map[common.Hash]time.Time),
+	}
+
+	client, sub, notifications, err := s.subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	availability.recordSuccess()
+
+	go s.run(ctx, client, sub, notifications)
+	return s, nil
+}
+
+func (s *inclusionStream) subscribe(ctx context.Context) (*rpc.Client, *rpc.ClientSubscription, chan json.RawMessage, error) {
+	client, err := rpc.DialContext(ctx, s.url)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to connect: %v", err)
+	}
+	if !client.SupportsSubscriptions() {
+		client.Close()
+		return nil, nil, nil, fmt.Errorf("endpoint does not support subscriptions")
+	}
+
+	notifications := make(chan json.RawMessage, 1024)
+	sub, err := client.EthSubscribe(ctx, notifications, s.topic)
+	if err != nil {
+		client.Close()
+		return nil, nil, nil, fmt.Errorf("unable to subscribe to %s: %v", s.topic, err)
+	}
+
+	return client, sub, notifications, nil
+}
+
+func (s *inclusionStream) run(ctx context.Context, client *rpc.Client, sub *rpc.ClientSubscription, notifications chan json.RawMessage) {
+	for {
+		select {
+		case <-ctx.Done():
+			sub.Unsubscribe()
+			client.Close()
+			return
+
+		case notification := <-notifications:
+			s.record(notification, time.Now())
+
+		case err := <-sub.Err():
+			log.Printf("Subscription to %s dropped: %v", s.topic, err)
+			s.availability.recordFailure()
+			client.Close()
+
+			for backoff := time.Second; ; backoff = min(2*backoff, 30*time.Second) {
+				pause(ctx, backoff)
+				if ctx.Err() != nil {
+					return
+				}
+
+				client, sub, notifications, err = s.subscribe(ctx)
+				if err == nil {
+					break
+				}
+				log.Printf("Failed to resubscribe to %s: %v", s.topic, err)
+				s.availability.recordFailure()
+			}
+
+			s.availability.recordReconnect()
+			s.availability.recordSuccess()
+		}
+	}
+}
+
+func (s *inclusionStream) record(notification json.RawMessage, seenAt time.Time) {
+	var payload interface{}
+	if err := json.Unmarshal(notification, &payload); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hash := range collectHashes(payload, nil) {
+		if at, ok := s.watched[hash]; ok && at.IsZero() {
+			s.watched[hash] = seenAt
+		}
+	}
+}
+
+// collectHashes finds transaction hashes in a notification. Endpoints differ
+// in what they push, so bare hashes, arrays of hashes, and objects with a hash
+// or transactionHash field at any depth are all accepted.
+func collectHashes(v interface{}, hashes []common.Hash) []common.Hash {
+	switch v := v.(type) {
+	case string:
+		if len(v) == 66 {
+			hashes = append(hashes, common.HexToHash(v))
+		}
+	case []interface{}:
+		for _, item := range v {
+			hashes = collectHashes(item, hashes)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if key == "hash" || key == "transactionHash" {
+				if hash, ok := item.(string); ok && len(hash) == 66 {
+					hashes = append(hashes, common.HexToHash(hash))
+				}
+				continue
+			}
+			if _, ok := item.(string); !ok {
+				hashes = collectHashes(item, hashes)
+			}
+		}
+	}
+	return hashes
+}
+
+// watch starts tracking a hash. It must be called before the transaction is
+// sent so an early notification isn't missed.
+func (s *inclusionStream) watch(hash common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watched[hash] = time.Time{}
+}
+
+// take stops tracking a hash and returns when its notification arrived,
+// waiting up to grace for one that hasn't arrived yet.
+func (s *inclusionStream) take(hash common.Hash, grace time.Duration) (time.Time, bool) {
+	deadline := time.Now().Add(grace)
+	for {
+		s.mu.Lock()
+		seenAt := s.watched[hash]
+		if !seenAt.IsZero() || time.Now().After(deadline) {
+			delete(s.watched, hash)
+			s.mu.Unlock()
+			return seenAt, !seenAt.IsZero()
+		}
+		s.mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type latencySummary struct {
+	Dimension string
+	Group     string
+	Count     int
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Regressed bool // against the baseline, when there is one
+}
+
+// percentile returns the nearest-rank percentile of an already sorted slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func summarize(dimension string, group string, delays []time.Duration) latencySummary {
+	sorted := append([]time.Duration(nil), delays...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return latencySummary{
+		Dimension: dimension,
+		Group:     group,
+		Count:     len(sorted),
+		P50:       percentile(sorted, 50),
+		P90:       percentile(sorted, 90),
+		P99:       percentile(sorted, 99),
+	}
+}
+
+// summarizeBy groups inclusion latency of included rows by the key returned
+// for each row. Rows with an empty key are skipped. Numeric keys sort
+// numerically, everything else alphabetically.
+func summarizeBy(dimension string, timings []stats, key func(stats) string) []latencySummary {
+	groups := make(map[string][]time.Duration)
+	for _, t := range timings {
+		if t.Status != statusIncluded {
+			continue
+		}
+		if k := key(t); k != "" {
+			groups[k] = append(groups[k], t.InclusionDelay)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+
+	var summaries []latencySummary
+	for _, k := range keys {
+		summaries = append(summaries, summarize(dimension, k, groups[k]))
+	}
+	return summaries
+}
+
+// summarizeByFlashblockIndex groups inclusion latency by the flashblock the
+// transaction landed in. Rows without a captured index are skipped.
+func summarizeByFlashblockIndex(timings []stats) []latencySummary {
+	return summarizeBy("flashblock_index", timings, func(t stats) string {
+		if t.FlashblockIndex == 0 {
+			return ""
+		}
+		return strconv.Itoa(t.FlashblockIndex)
+	})
+}
+
+// summarizeByLane compares inclusion latency across submission lanes.
+func summarizeByLane(timings []stats) []latencySummary {
+	return summarizeBy("lane", timings, func(t stats) string {
+		return t.Lane
+	})
+}
+
+// summarizeByDelivery compares push and poll latency over the rows where a
+// push notification was received, so both are measured on the same
+// transactions.
+func summarizeByDelivery(timings []stats) []latencySummary {
+	var poll, push []time.Duration
+	for _, t := range timings {
+		if t.Status != statusIncluded || t.PushInclusionDelay == 0 {
+			continue
+		}
+		poll = append(poll, t.InclusionDelay)
+		push = append(push, t.PushInclusionDelay)
+	}
+
+	if len(push) == 0 {
+		return nil
+	}
+	return []latencySummary{
+		summarize("delivery", "poll", poll),
+		summarize("delivery", "push", push),
+	}
+}
+
+// summarizeRun computes every latency summary of a run: flashblock rows by
+// index, and all rows by lane and delivery.
+func summarizeRun(flashblockTimings []stats, baseTimings []stats) []latencySummary {
+	allTimings := append(append([]stats{}, flashblockTimings...), baseTimings...)
+	summaries := summarizeByFlashblockIndex(flashblockTimings)
+	summaries = append(summaries, summarizeByLane(allTimings)...)
+	return append(summaries, summarizeByDelivery(allTimings)...)
+}
+
+func logSummaries(summaries []latencySummary) {
+	for _, s := range summaries {
+		log.Printf("%s=%s count=%d p50=%dms p90=%dms p99=%dms", s.Dimension, s.Group, s.Count, s.P50.Milliseconds(), s.P90.Milliseconds(), s.P99.Milliseconds())
+	}
+}
+
+func writeSummaries(filename string, summaries []latencySummary) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"dimension", "group", "count", "p50_ms", "p90_ms", "p99_ms", "regressed"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	for _, s := range summaries {
+		row := []string{
+			s.Dimension,
+			s.Group,
+			strconv.Itoa(s.Count),
+			strconv.FormatInt(s.P50.Milliseconds(), 10),
+			strconv.FormatInt(s.P90.Milliseconds(), 10),
+			strconv.FormatInt(s.P99.Milliseconds(), 10),
+			strconv.FormatBool(s.Regressed),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("unable to write row: %v", err)
+		}
+	}
+
+	return nil
+}
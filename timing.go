@@ -0,0 +1,33 @@
+package main
+
+import "time"
+
+// Every timestamp in a row is taken with time.Now, which carries both a wall
+// clock and a monotonic reading. Durations between two such timestamps use the
+// monotonic reading and are immune to NTP adjustments, while the wall clock is
+// what block timestamps and other hosts are compared against. Rows keep both
+// and are flagged when they disagree, meaning the wall clock was stepped while
+// the transaction was in flight.
+
+// wallClock drops the monotonic reading so comparisons use the wall clock only.
+func wallClock(t time.Time) time.Time {
+	return t.Round(0)
+}
+
+// markIncluded records when the transaction was first observed as included.
+func (s *stats) markIncluded(observedAt time.Time) {
+	s.IncludedAt = observedAt
+	s.InclusionDelay = observedAt.Sub(s.SentAt)
+	s.WallInclusionDelay = wallClock(observedAt).Sub(wallClock(s.SentAt))
+}
+
+// flagClockJump marks the row when wall and monotonic delays differ by more
+// than the tolerance, and reports whether it did.
+func (s *stats) flagClockJump(tolerance time.Duration) bool {
+	drift := s.WallInclusionDelay - s.InclusionDelay
+	if drift < 0 {
+		drift = -drift
+	}
+	s.ClockJump = drift > tolerance
+	return s.ClockJump
+}
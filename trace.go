@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newTraceID returns a random correlation ID for a transaction.
+func newTraceID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// traceContext attaches the correlation ID as an HTTP header to requests made
+// with the returned context, so gateway logs can be joined with result rows.
+func traceContext(header string, traceID string) context.Context {
+	return rpc.NewContextWithHeaders(context.Background(), http.Header{header: []string{traceID}})
+}